@@ -0,0 +1,134 @@
+package pulse
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// AliasRule renames a repository. It either matches the name, or fully
+// qualified identity, of the repository exactly through From, or through
+// the regular expression in Pattern. To may refer to the submatches of the
+// pattern, e.g. $1.
+type AliasRule struct {
+	From    string
+	Pattern string
+	To      string
+}
+
+// compiledAlias is an alias rule with its pattern compiled.
+type compiledAlias struct {
+	from    string
+	pattern *regexp.Regexp
+	to      string
+}
+
+// Aliases resolves the canonical names of repositories that have been
+// renamed or forked, which keeps their history under a single name.
+type Aliases struct {
+	rules []compiledAlias
+}
+
+// NewAliases compiles the rules. The rules are applied in order,
+// and the first one that matches a repository is used.
+func NewAliases(rules []AliasRule) (Aliases, error) {
+	aliases := Aliases{rules: make([]compiledAlias, 0, len(rules))}
+	for _, rule := range rules {
+		if rule.To == "" || (rule.From == "") == (rule.Pattern == "") {
+			return Aliases{}, fmt.Errorf("an alias needs a target and either a name or a pattern: %+v", rule)
+		}
+
+		compiled := compiledAlias{from: rule.From, to: rule.To}
+		if rule.Pattern != "" {
+			pattern, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return Aliases{}, fmt.Errorf("invalid alias pattern: %w", err)
+			}
+			compiled.pattern = pattern
+		}
+		aliases.rules = append(aliases.rules, compiled)
+	}
+	return aliases, nil
+}
+
+// Resolve returns the canonical name. Names without an alias are returned as is.
+func (a Aliases) Resolve(name string) string {
+	if name == "" {
+		return name
+	}
+
+	for _, rule := range a.rules {
+		if rule.pattern == nil {
+			if rule.from == name {
+				return rule.to
+			}
+			continue
+		}
+		if rule.pattern.MatchString(name) {
+			return rule.pattern.ReplaceAllString(name, rule.to)
+		}
+	}
+	return name
+}
+
+// resolveRepository returns the canonical name and identity of a repository.
+// An identity that still refers to the old name would keep the repositories
+// apart, which is why it's cleared when the name is renamed, unless a rule
+// renames the identity as well. Repositories without an identity are merged
+// into the repository with the same name. Likewise, a rule that only renames
+// the identity renames the repository after the last element of the new one.
+func (a Aliases) resolveRepository(name, id string) (string, string) {
+	resolvedName, resolvedID := a.Resolve(name), a.Resolve(id)
+	switch {
+	case resolvedName != name && resolvedID == id:
+		resolvedID = ""
+	case resolvedName == name && resolvedID != id:
+		resolvedName = path.Base(resolvedID)
+	}
+	return resolvedName, resolvedID
+}
+
+// renamePath replaces the repository name that prefixes the path of a file.
+func renamePath(p, from, to string) string {
+	if from == to {
+		return p
+	}
+	if rest, ok := strings.CutPrefix(p, from+"/"); ok {
+		return to + "/" + rest
+	}
+	return p
+}
+
+// ResolveFile applies the aliases to the repositories of the file.
+func (a Aliases) ResolveFile(file GitFile) GitFile {
+	name, id := a.resolveRepository(file.Repository, file.RepositoryID)
+	file.Path = renamePath(file.Path, file.Repository, name)
+	file.Repository = name
+	file.RepositoryID = id
+	file.Superproject = a.Resolve(file.Superproject)
+	return file
+}
+
+// ResolveSession applies the aliases to the repositories of a session that has
+// already been stored. Repositories that end up with the same name are merged.
+func (a Aliases) ResolveSession(session CodingSession) CodingSession {
+	repositories := make(Repositories, 0, len(session.Repositories))
+	for _, repo := range session.Repositories {
+		name, id := a.resolveRepository(repo.Name, repo.ID)
+		files := make(Files, 0, len(repo.Files))
+		for _, file := range repo.Files {
+			file.Path = renamePath(file.Path, repo.Name, name)
+			files = append(files, file)
+		}
+
+		repo.Name = name
+		repo.ID = id
+		repo.Superproject = a.Resolve(repo.Superproject)
+		repo.Files = files
+		repositories = repositories.merge(Repositories{repo})
+	}
+
+	session.Repositories = repositories
+	return session
+}
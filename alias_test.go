@@ -0,0 +1,168 @@
+package pulse_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/viccon/pulse"
+)
+
+func TestResolveAliases(t *testing.T) {
+	t.Parallel()
+
+	aliases, err := pulse.NewAliases([]pulse.AliasRule{
+		{From: "pulse-old", To: "pulse"},
+		{From: "github.com/viccon/pulse-old", To: "github.com/viccon/pulse"},
+		{Pattern: "^acme-(.*)-fork$", To: "acme-$1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"pulse-old":                   "pulse",
+		"github.com/viccon/pulse-old": "github.com/viccon/pulse",
+		"acme-api-fork":               "acme-api",
+		"sturdyc":                     "sturdyc",
+		"":                            "",
+	}
+	for name, expected := range tests {
+		if got := aliases.Resolve(name); got != expected {
+			t.Errorf("Resolve(%s) = %s; expected %s", name, got, expected)
+		}
+	}
+
+	file := aliases.ResolveFile(pulse.GitFile{
+		Repository:   "pulse-old",
+		RepositoryID: "github.com/viccon/pulse-old",
+		Path:         "pulse-old/server/server.go",
+	})
+	if file.Repository != "pulse" || file.RepositoryID != "github.com/viccon/pulse" || file.Path != "pulse/server/server.go" {
+		t.Errorf("unexpected file: %+v", file)
+	}
+}
+
+func TestResolveAliasesMergesRepositories(t *testing.T) {
+	t.Parallel()
+
+	aliases, err := pulse.NewAliases([]pulse.AliasRule{{From: "pulse-old", To: "pulse"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session := pulse.CodingSession{
+		Repositories: pulse.Repositories{
+			{ID: "github.com/viccon/pulse-old", Name: "pulse-old", Duration: 10 * time.Minute, Files: pulse.Files{
+				{Name: "main.go", Path: "pulse-old/main.go", Filetype: "go", Duration: 10 * time.Minute},
+			}},
+			{ID: "github.com/viccon/pulse", Name: "pulse", Duration: 5 * time.Minute, Files: pulse.Files{
+				{Name: "main.go", Path: "pulse/main.go", Filetype: "go", Duration: 5 * time.Minute},
+			}},
+		},
+	}
+
+	resolved := aliases.ResolveSession(session)
+	if len(resolved.Repositories) != 1 {
+		t.Fatalf("expected the repositories to be merged; got %d", len(resolved.Repositories))
+	}
+	repo := resolved.Repositories[0]
+	if repo.Name != "pulse" || repo.ID != "github.com/viccon/pulse" || repo.Duration != 15*time.Minute {
+		t.Errorf("unexpected repository: %+v", repo)
+	}
+	if len(repo.Files) != 1 || repo.Files[0].Duration != 15*time.Minute {
+		t.Errorf("expected the files to be merged; got %+v", repo.Files)
+	}
+}
+
+func TestResolveAliasesClearsTheOldIdentity(t *testing.T) {
+	t.Parallel()
+
+	aliases, err := pulse.NewAliases([]pulse.AliasRule{{Pattern: "^acme-(.*)-fork$", To: "acme-$1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The identity of the fork would keep it apart from the upstream repository.
+	file := aliases.ResolveFile(pulse.GitFile{
+		Repository:   "acme-api-fork",
+		RepositoryID: "github.com/viccon/acme-api-fork",
+		Path:         "acme-api-fork/main.go",
+	})
+	if file.Repository != "acme-api" || file.RepositoryID != "" || file.Path != "acme-api/main.go" {
+		t.Errorf("unexpected file: %+v", file)
+	}
+
+	session := aliases.ResolveSession(pulse.CodingSession{
+		Repositories: pulse.Repositories{
+			{ID: "github.com/viccon/acme-api-fork", Name: "acme-api-fork", Duration: 10 * time.Minute},
+			{ID: "github.com/acme/acme-api", Name: "acme-api", Duration: 5 * time.Minute},
+		},
+	})
+	if len(session.Repositories) != 1 {
+		t.Fatalf("expected the fork to be merged into the upstream repository; got %+v", session.Repositories)
+	}
+	if repo := session.Repositories[0]; repo.ID != "github.com/acme/acme-api" || repo.Duration != 15*time.Minute {
+		t.Errorf("unexpected repository: %+v", repo)
+	}
+}
+
+func TestResolveAliasesRenamesAfterTheIdentity(t *testing.T) {
+	t.Parallel()
+
+	aliases, err := pulse.NewAliases([]pulse.AliasRule{{From: "github.com/viccon/pulse-old", To: "github.com/viccon/pulse"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A rule that only matches the identity should rename the repository too.
+	file := aliases.ResolveFile(pulse.GitFile{
+		Repository:   "pulse-old",
+		RepositoryID: "github.com/viccon/pulse-old",
+		Path:         "pulse-old/main.go",
+	})
+	if file.Repository != "pulse" || file.RepositoryID != "github.com/viccon/pulse" || file.Path != "pulse/main.go" {
+		t.Errorf("unexpected file: %+v", file)
+	}
+
+	session := aliases.ResolveSession(pulse.CodingSession{
+		Repositories: pulse.Repositories{
+			{
+				ID:       "github.com/viccon/pulse-old",
+				Name:     "pulse-old",
+				Duration: 10 * time.Minute,
+				Files:    pulse.Files{{Name: "main.go", Path: "pulse-old/main.go", Duration: 10 * time.Minute}},
+			},
+			{
+				ID:       "github.com/viccon/pulse",
+				Name:     "pulse",
+				Duration: 5 * time.Minute,
+				Files:    pulse.Files{{Name: "main.go", Path: "pulse/main.go", Duration: 5 * time.Minute}},
+			},
+		},
+	})
+	if len(session.Repositories) != 1 {
+		t.Fatalf("expected the repositories to be merged; got %+v", session.Repositories)
+	}
+	repo := session.Repositories[0]
+	if repo.ID != "github.com/viccon/pulse" || repo.Name != "pulse" || repo.Duration != 15*time.Minute {
+		t.Errorf("unexpected repository: %+v", repo)
+	}
+	if len(repo.Files) != 1 || repo.Files[0].Path != "pulse/main.go" || repo.Files[0].Duration != 15*time.Minute {
+		t.Errorf("expected the files to be merged; got %+v", repo.Files)
+	}
+}
+
+func TestInvalidAliases(t *testing.T) {
+	t.Parallel()
+
+	invalid := []pulse.AliasRule{
+		{From: "pulse-old"},
+		{From: "pulse-old", Pattern: "pulse", To: "pulse"},
+		{Pattern: "(", To: "pulse"},
+	}
+	for _, rule := range invalid {
+		if _, err := pulse.NewAliases([]pulse.AliasRule{rule}); err == nil {
+			t.Errorf("expected the rule %+v to be rejected", rule)
+		}
+	}
+}
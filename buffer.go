@@ -3,18 +3,31 @@ package pulse
 import (
 	"cmp"
 	"fmt"
+	"strings"
 	"time"
 )
 
+// keyDateLayout is the layout of the date that prefixes every buffer key.
+const keyDateLayout = "2006-01-02"
+
 // Buffer rerpresents a buffer that has been edited during a coding session.
 type Buffer struct {
-	OpenedAt   time.Time     `json:"-"`
-	ClosedAt   time.Time     `json:"-"`
-	Duration   time.Duration `json:"duration"`
-	Filename   string        `json:"filename"`
-	Filepath   string        `json:"filepath"`
-	Filetype   string        `json:"filetype"`
-	Repository string        `json:"repository"`
+	OpenedAt     time.Time     `json:"opened_at"`
+	ClosedAt     time.Time     `json:"closed_at"`
+	Duration     time.Duration `json:"duration"`
+	Filename     string        `json:"filename"`
+	Filepath     string        `json:"filepath"`
+	Filetype     string        `json:"filetype"`
+	Repository   string        `json:"repository"`
+	RepositoryID string        `json:"repository_id,omitempty"`
+	Branch       string        `json:"branch"`
+	Ticket       string        `json:"ticket"`
+	// Superproject is the repository that contains the submodule
+	// that the buffer belongs to, if it's part of a submodule.
+	Superproject string `json:"superproject,omitempty"`
+	Editor       string `json:"editor"`
+	OS           string `json:"os"`
+	Hostname     string `json:"hostname"`
 }
 
 // NewBuffer creates a new buffer.
@@ -34,19 +47,67 @@ func (b *Buffer) Close(closedAt time.Time) {
 	b.Duration = b.ClosedAt.Sub(b.OpenedAt)
 }
 
-// Key returns a unique identifier for the buffer.
+// SplitByDay splits the buffer at every local midnight between the time it
+// was opened and closed. This ensures that each buffer belongs to a single
+// day, which is important for the daily totals when we work late at night.
+func (b *Buffer) SplitByDay() Buffers {
+	buffers := make(Buffers, 0, 1)
+	openedAt := b.OpenedAt
+	for {
+		part := *b
+		part.OpenedAt = openedAt
+		midnight := TruncateDay(openedAt).AddDate(0, 0, 1)
+		if !b.ClosedAt.After(midnight) {
+			part.Close(b.ClosedAt)
+			return append(buffers, part)
+		}
+
+		part.Close(midnight)
+		buffers = append(buffers, part)
+		openedAt = midnight
+	}
+}
+
+// Key returns a unique identifier for the buffer. Buffers for the same file are
+// kept apart if they were opened on different branches, editors or machines.
 func (b *Buffer) Key() string {
-	return fmt.Sprintf("%s_%s_%s", b.OpenedAt.Format("2006-01-02"), b.Repository, b.Filepath)
+	return fmt.Sprintf("%s_%s_%s_%s_%s_%s",
+		b.OpenedAt.Format(keyDateLayout),
+		cmp.Or(b.RepositoryID, b.Repository),
+		b.Filepath,
+		b.Branch,
+		b.Editor,
+		b.Hostname,
+	)
+}
+
+// KeyDate returns the day that is encoded in a buffer key.
+func KeyDate(key string) (time.Time, error) {
+	date, _, _ := strings.Cut(key, "_")
+	return time.ParseInLocation(keyDateLayout, date, time.Local)
+}
+
+// KeyPrefix returns the prefix that is shared by the keys of every buffer
+// that was opened on the same day as t. It can be used to scan a day.
+func KeyPrefix(t time.Time) string {
+	return t.Format(keyDateLayout) + "_"
 }
 
 // Merge takes two buffers, merges them, and returns the result.
 func (b *Buffer) Merge(other Buffer) Buffer {
 	return Buffer{
-		Filename:   cmp.Or(b.Filename, other.Filename),
-		Filepath:   cmp.Or(b.Filepath, other.Filepath),
-		Filetype:   cmp.Or(b.Filetype, other.Filetype),
-		Repository: cmp.Or(b.Repository, other.Repository),
-		Duration:   b.Duration + other.Duration,
+		Filename:     cmp.Or(b.Filename, other.Filename),
+		Filepath:     cmp.Or(b.Filepath, other.Filepath),
+		Filetype:     cmp.Or(b.Filetype, other.Filetype),
+		Repository:   cmp.Or(b.Repository, other.Repository),
+		RepositoryID: cmp.Or(b.RepositoryID, other.RepositoryID),
+		Branch:       cmp.Or(b.Branch, other.Branch),
+		Ticket:       cmp.Or(b.Ticket, other.Ticket),
+		Superproject: cmp.Or(b.Superproject, other.Superproject),
+		Editor:       cmp.Or(b.Editor, other.Editor),
+		OS:           cmp.Or(b.OS, other.OS),
+		Hostname:     cmp.Or(b.Hostname, other.Hostname),
+		Duration:     b.Duration + other.Duration,
 	}
 }
 
@@ -0,0 +1,55 @@
+package pulse_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/viccon/pulse"
+)
+
+func TestSplitBufferByDay(t *testing.T) {
+	t.Parallel()
+
+	// 23:30 Sunday June 16 2024
+	openedAt := time.Date(2024, time.June, 16, 23, 30, 0, 0, time.Local)
+	buf := pulse.NewBuffer("logdb.go", "pulse", "go", "pulse/logdb.go", openedAt)
+	buf.Branch, buf.Editor, buf.OS, buf.Hostname = "main", "nvim", "darwin", "laptop"
+	// 01:30 Tuesday June 18 2024
+	buf.Close(openedAt.Add(26 * time.Hour))
+
+	buffers := buf.SplitByDay()
+	if len(buffers) != 3 {
+		t.Fatalf("expected 3 buffers, got %d", len(buffers))
+	}
+
+	expectedDurations := []time.Duration{30 * time.Minute, 24 * time.Hour, 90 * time.Minute}
+	expectedKeys := []string{
+		"2024-06-16_pulse_pulse/logdb.go_main_nvim_laptop",
+		"2024-06-17_pulse_pulse/logdb.go_main_nvim_laptop",
+		"2024-06-18_pulse_pulse/logdb.go_main_nvim_laptop",
+	}
+	for i, b := range buffers {
+		if b.Duration != expectedDurations[i] {
+			t.Errorf("expected buffer %d to be %v, got %v", i, expectedDurations[i], b.Duration)
+		}
+		if b.Key() != expectedKeys[i] {
+			t.Errorf("expected buffer %d to have the key %s, got %s", i, expectedKeys[i], b.Key())
+		}
+	}
+}
+
+func TestSplitBufferWithinOneDay(t *testing.T) {
+	t.Parallel()
+
+	openedAt := time.Date(2024, time.June, 16, 12, 0, 0, 0, time.Local)
+	buf := pulse.NewBuffer("logdb.go", "pulse", "go", "pulse/logdb.go", openedAt)
+	buf.Close(openedAt.Add(12 * time.Hour))
+
+	buffers := buf.SplitByDay()
+	if len(buffers) != 1 {
+		t.Fatalf("expected 1 buffer, got %d", len(buffers))
+	}
+	if buffers[0].Duration != 12*time.Hour {
+		t.Errorf("expected the buffer to be 12 hours, got %v", buffers[0].Duration)
+	}
+}
@@ -2,9 +2,14 @@
 package client
 
 import (
+	"cmp"
 	"fmt"
 	"net/rpc"
+	"os"
 	"runtime"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/viccon/pulse"
 )
@@ -12,12 +17,13 @@ import (
 // Client for making remote procedure calls to the server.
 type Client struct {
 	serverName string
+	machine    string
 	rpcClient  *rpc.Client
 }
 
-// createEvents creates a new event from the slice of arguments
-// that we receive from the neovim client.
-func createEvent(args []string) pulse.Event {
+// createEvents creates a new event from the slice of arguments that
+// we receive from the neovim client, and the name of the machine.
+func createEvent(args []string, machine string) pulse.Event {
 	filetype := args[2]
 	if filetype == "typescript.tsx" {
 		filetype = "typescript"
@@ -29,6 +35,7 @@ func createEvent(args []string) pulse.Event {
 		Filetype: filetype,
 		Editor:   "nvim",
 		OS:       runtime.GOOS,
+		Hostname: machine,
 	}
 }
 
@@ -39,12 +46,16 @@ func New(serverName, port, hostname string) (*Client, error) {
 		return nil, err
 	}
 
-	return &Client{serverName: serverName, rpcClient: rpcClient}, nil
+	// The hostname is only used to tell machines apart, so we'll
+	// still send events with an empty name if it can't be determined.
+	machine, _ := os.Hostname()
+
+	return &Client{serverName: serverName, machine: machine, rpcClient: rpcClient}, nil
 }
 
 // FocusGained should be called when a buffer gains focus.
 func (c *Client) FocusGained(args []string) {
-	event, reply := createEvent(args), ""
+	event, reply := createEvent(args, c.machine), ""
 	serviceMethod := c.serverName + ".FocusGained"
 	//nolint: errcheck // I don't want to print eventual errors in the editor.
 	c.rpcClient.Call(serviceMethod, event, &reply)
@@ -53,7 +64,7 @@ func (c *Client) FocusGained(args []string) {
 // OpenFile should be called when a buffer is opened. The server
 // will check if the path is a valid file.
 func (c *Client) OpenFile(args []string) {
-	event, reply := createEvent(args), ""
+	event, reply := createEvent(args, c.machine), ""
 	serviceMethod := c.serverName + ".OpenFile"
 	//nolint: errcheck // I don't want to print eventual errors in the editor.
 	c.rpcClient.Call(serviceMethod, event, &reply)
@@ -61,9 +72,9 @@ func (c *Client) OpenFile(args []string) {
 
 // SendHeartbeat can be called for events such as buffer writes and cursor moves.
 // Its purpose is to notify the server that the current session remains active.
-// The server ends the session if we don't perform any actions for 10 minutes.
+// The server ends the session if we don't perform any actions before the heartbeat TTL expires.
 func (c *Client) SendHeartbeat(args []string) {
-	event, reply := createEvent(args), ""
+	event, reply := createEvent(args, c.machine), ""
 	serviceMethod := c.serverName + ".SendHeartbeat"
 	//nolint: errcheck // I don't want to print eventual errors in the editor.
 	c.rpcClient.Call(serviceMethod, event, &reply)
@@ -71,8 +82,36 @@ func (c *Client) SendHeartbeat(args []string) {
 
 // EndSession should be called when the neovim process ends.
 func (c *Client) EndSession(args []string) {
-	event, reply := createEvent(args), ""
+	event, reply := createEvent(args, c.machine), ""
 	serviceMethod := c.serverName + ".EndSession"
 	//nolint: errcheck // I don't want to print eventual errors in the editor.
 	c.rpcClient.Call(serviceMethod, event, &reply)
 }
+
+// Today returns a summary of what has been worked on today,
+// with the total duration and the time spent in each repository.
+func (c *Client) Today(args []string) (string, error) {
+	event, reply := createEvent(args, c.machine), pulse.CodingSession{}
+	serviceMethod := c.serverName + ".Today"
+	if err := c.rpcClient.Call(serviceMethod, event, &reply); err != nil {
+		return "", err
+	}
+
+	repositories := slices.Clone(reply.Repositories)
+	slices.SortFunc(repositories, func(a, b pulse.Repository) int {
+		return cmp.Compare(b.Duration, a.Duration)
+	})
+
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "Today: %s", formatDuration(reply.Duration))
+	for _, repo := range repositories {
+		fmt.Fprintf(&summary, "\n  %s: %s", repo.Name, formatDuration(repo.Duration))
+	}
+	return summary.String(), nil
+}
+
+// formatDuration formats the duration in hours and minutes, e.g. 1h 05m.
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	return fmt.Sprintf("%dh %02dm", int(d.Hours()), int(d.Minutes())%60)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/viccon/pulse"
+	"github.com/viccon/pulse/redis"
+)
+
+// names returns the names of the repositories in the session.
+func names(session pulse.CodingSession) []string {
+	n := make([]string, 0, len(session.Repositories))
+	for _, repo := range session.Repositories {
+		n = append(n, repo.Name)
+	}
+	return n
+}
+
+// hasAliases reports whether any of the repositories in the session are renamed.
+func hasAliases(aliases pulse.Aliases, session pulse.CodingSession) bool {
+	for _, repo := range session.Repositories {
+		if aliases.Resolve(repo.Name) != repo.Name || aliases.Resolve(repo.ID) != repo.ID ||
+			aliases.Resolve(repo.Superproject) != repo.Superproject {
+			return true
+		}
+	}
+	return false
+}
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "print the sessions that would be rewritten without writing them")
+	flag.Parse()
+
+	cfg, err := pulse.ParseConfig()
+	if err != nil {
+		panic("failed to parse config")
+	}
+
+	aliases, err := pulse.NewAliases(cfg.Aliases)
+	if err != nil {
+		panic(err)
+	}
+
+	ctx := context.Background()
+	redisClient := redis.New(cfg.Database.Address, cfg.Database.Password)
+	sessions, err := redisClient.ReadAll(ctx)
+	if err != nil {
+		panic(err)
+	}
+
+	// Rewrite the stored sessions with the aliases applied.
+	for _, session := range sessions {
+		if !hasAliases(aliases, session) {
+			continue
+		}
+
+		resolved := aliases.ResolveSession(session)
+		fmt.Printf("%s: %v -> %v\n", session.DateString(), names(session), names(resolved))
+		if *dryRun {
+			continue
+		}
+		if err = redisClient.Replace(ctx, resolved); err != nil {
+			panic(err)
+		}
+	}
+}
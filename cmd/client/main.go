@@ -22,6 +22,7 @@ func main() {
 		p.HandleFunction(&plugin.FunctionOptions{Name: "OpenFile"}, client.OpenFile)
 		p.HandleFunction(&plugin.FunctionOptions{Name: "SendHeartbeat"}, client.SendHeartbeat)
 		p.HandleFunction(&plugin.FunctionOptions{Name: "EndSession"}, client.EndSession)
+		p.HandleFunction(&plugin.FunctionOptions{Name: "Today"}, client.Today)
 		return nil
 	})
 }
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/viccon/pulse"
+	"github.com/viccon/pulse/redis"
+)
+
+const dateLayout = "2006-01-02"
+
+func main() {
+	today := time.Now().Format(dateLayout)
+	fromFlag := flag.String("from", today, "the first day of the report (YYYY-MM-DD)")
+	toFlag := flag.String("to", today, "the last day of the report (YYYY-MM-DD)")
+	flag.Parse()
+
+	from, err := time.ParseInLocation(dateLayout, *fromFlag, time.Local)
+	if err != nil {
+		panic(fmt.Sprintf("invalid from date: %v", err))
+	}
+	to, err := time.ParseInLocation(dateLayout, *toFlag, time.Local)
+	if err != nil {
+		panic(fmt.Sprintf("invalid to date: %v", err))
+	}
+
+	cfg, err := pulse.ParseConfig()
+	if err != nil {
+		panic("failed to parse config")
+	}
+
+	redisClient := redis.New(cfg.Database.Address, cfg.Database.Password)
+	sessions, err := redisClient.ReadAll(context.Background())
+	if err != nil {
+		panic(err)
+	}
+
+	tickets := sessions.Tickets(from, to)
+	ids := make([]string, 0, len(tickets))
+	for id := range tickets {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return tickets[ids[i]] > tickets[ids[j]]
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TICKET\tHOURS")
+	for _, id := range ids {
+		fmt.Fprintf(w, "%s\t%.2f\n", id, tickets[id].Hours())
+	}
+	w.Flush()
+}
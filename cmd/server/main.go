@@ -8,10 +8,36 @@ import (
 	"syscall"
 
 	"github.com/viccon/pulse"
+	"github.com/viccon/pulse/outbox"
+	"github.com/viccon/pulse/privacy"
 	"github.com/viccon/pulse/redis"
 	"github.com/viccon/pulse/server"
 )
 
+// newPrivacyWriter redacts the file paths of the sessions before
+// they are written to redis, according to the privacy config.
+func newPrivacyWriter(cfg *pulse.Config, userHomeDir string, next privacy.SessionWriter) *privacy.Writer {
+	mode, err := privacy.ParseMode(cfg.Privacy.Mode)
+	if err != nil {
+		panic(err)
+	}
+	opts := []privacy.Option{privacy.WithMode(mode)}
+	for _, rule := range cfg.Privacy.Repositories {
+		repositoryMode, modeErr := privacy.ParseMode(rule.Mode)
+		if modeErr != nil {
+			panic(modeErr)
+		}
+		opts = append(opts, privacy.WithRepositoryMode(rule.Repository, repositoryMode))
+	}
+
+	// The salt is kept locally, which prevents the hashes from being reversed.
+	salt, err := privacy.LoadSalt(path.Join(userHomeDir, ".pulse", "salt"))
+	if err != nil {
+		panic(err)
+	}
+	return privacy.New(next, salt, opts...)
+}
+
 func main() {
 	cfg, err := pulse.ParseConfig()
 	if err != nil {
@@ -28,10 +54,18 @@ func main() {
 		panic(err)
 	}
 
+	// Sessions are persisted in an outbox until they have been written to redis.
+	outboxPath := path.Join(userHomeDir, ".pulse", "outbox")
+	sessionOutbox, err := outbox.New(outboxPath, newPrivacyWriter(cfg, userHomeDir, redisClient))
+	if err != nil {
+		panic(err)
+	}
+	go sessionOutbox.Run(ctx)
+
 	// Create the path for the log storages segment files.
 	segmentPath := path.Join(userHomeDir, ".pulse", "segments")
 
-	server := server.New(cfg, segmentPath, redisClient)
+	server := server.New(cfg, segmentPath, sessionOutbox)
 	server.RunBackgroundJobs(ctx, cfg.Server.SegmentationInterval)
 
 	err = server.StartServer(ctx, cfg.Server.Port)
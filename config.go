@@ -1,9 +1,21 @@
 package pulse
 
 import (
+	"fmt"
+	"regexp"
 	"time"
 
 	"github.com/spf13/viper"
+	"github.com/viccon/pulse/ignore"
+)
+
+// The editor policies decide how time is credited when
+// several editor instances are open at the same time.
+const (
+	// EditorPolicyFocus credits the editor that opened a buffer most recently.
+	EditorPolicyFocus = "focus"
+	// EditorPolicyParallel credits every editor that has an active buffer.
+	EditorPolicyParallel = "parallel"
 )
 
 type Config struct {
@@ -14,11 +26,64 @@ type Config struct {
 		AggregationInterval  time.Duration
 		SegmentationInterval time.Duration
 		SegmentSizeKB        int
+		// IdleGracePeriod is added to the time of the last heartbeat
+		// when a buffer is closed because the session went idle.
+		IdleGracePeriod time.Duration
+		// HeartbeatTTL is the amount of time that a session can go
+		// without a heartbeat before it's considered to be idle.
+		HeartbeatTTL time.Duration
+		// HeartbeatInterval determines how often we check for idle sessions.
+		HeartbeatInterval time.Duration
+		// FiletypeHeartbeatTTL overrides the HeartbeatTTL for specific filetypes.
+		FiletypeHeartbeatTTL map[string]time.Duration
+		// EditorPolicy is either "focus" or "parallel". It decides how
+		// time is credited when several editors are open at once.
+		// An empty string defaults to "focus".
+		EditorPolicy string
 	}
 	Database struct {
 		Address  string
 		Password string
 	}
+	Git struct {
+		// TicketPattern is a regular expression that is used to
+		// extract ticket ids, such as PULSE-123, from branch names.
+		TicketPattern string
+		// RecordSuperproject records the repository that a submodule
+		// belongs to, in addition to the submodule's own repository.
+		RecordSuperproject bool
+		// Remote is the name of the remote that repositories are named after.
+		// Other remotes, and then the directory name, are used as fallbacks.
+		Remote string
+		// ProjectMarkers are the files and directories, such as go.mod or .hg,
+		// that identify projects which aren't git repositories.
+		ProjectMarkers []string
+	}
+	Ignore struct {
+		// Repositories are glob patterns for the names, or fully
+		// qualified identities, of repositories that aren't tracked.
+		Repositories []string
+		// Paths are glob patterns for the files that aren't tracked.
+		// They are matched against the path within the repository.
+		Paths []string
+	}
+	Privacy struct {
+		// Mode is one of "keep", "directories", "hash" or "drop". It decides
+		// how much of the file paths that are written to the remote storage.
+		Mode string
+		// Repositories overrides the mode for specific repositories.
+		Repositories []PrivacyRule
+	}
+	// Aliases renames repositories, which keeps the history of
+	// projects that have been renamed or forked under one name.
+	Aliases []AliasRule
+}
+
+// PrivacyRule overrides the privacy mode of a repository. The repository
+// is either its name, or its fully qualified identity.
+type PrivacyRule struct {
+	Repository string
+	Mode       string
 }
 
 func ParseConfig() (*Config, error) {
@@ -32,5 +97,27 @@ func ParseConfig() (*Config, error) {
 
 	var cfg Config
 	err = viper.Unmarshal(&cfg)
-	return &cfg, err
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.Server.EditorPolicy {
+	case "", EditorPolicyFocus, EditorPolicyParallel:
+	default:
+		return nil, fmt.Errorf("invalid editor policy: %q", cfg.Server.EditorPolicy)
+	}
+	if _, err = regexp.Compile(cfg.Git.TicketPattern); err != nil {
+		return nil, fmt.Errorf("invalid ticket pattern: %w", err)
+	}
+	if _, err = ignore.New(cfg.Ignore.Repositories); err != nil {
+		return nil, err
+	}
+	if _, err = ignore.New(cfg.Ignore.Paths); err != nil {
+		return nil, err
+	}
+	if _, err = NewAliases(cfg.Aliases); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
 }
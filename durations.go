@@ -0,0 +1,32 @@
+package pulse
+
+import "time"
+
+// Durations maps a name, such as an editor or a machine,
+// to the amount of time that was spent on it.
+type Durations map[string]time.Duration
+
+// add increments the duration for the given name. Empty names are
+// ignored, as they come from buffers that lack the information.
+func (d Durations) add(name string, duration time.Duration) {
+	if name == "" {
+		return
+	}
+	d[name] += duration
+}
+
+// merge takes two durations, merges them, and returns the result.
+func (d Durations) merge(other Durations) Durations {
+	if len(d) == 0 && len(other) == 0 {
+		return nil
+	}
+
+	merged := make(Durations, len(d)+len(other))
+	for name, duration := range d {
+		merged[name] += duration
+	}
+	for name, duration := range other {
+		merged[name] += duration
+	}
+	return merged
+}
@@ -7,4 +7,5 @@ type Event struct {
 	Filetype string
 	Editor   string
 	OS       string
+	Hostname string
 }
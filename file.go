@@ -10,7 +10,18 @@ type GitFile struct {
 	Name       string
 	Filetype   string
 	Repository string
-	Path       string
+	// RepositoryID is the fully qualified identity of the repository, e.g.
+	// github.com/viccon/pulse. It's empty for repositories without remotes.
+	RepositoryID string
+	Branch       string
+	Ticket       string
+	Path         string
+	// Superproject is the name of the repository that contains
+	// the submodule. It's empty for files outside of submodules.
+	Superproject string
+	// Ignored is true if the file matches a pattern in the .pulseignore
+	// file that has been checked into the root of the repository.
+	Ignored bool
 }
 
 // File represents a file that has been aggregated
@@ -36,12 +47,20 @@ func (a File) merge(b File) File {
 // for a given time period (day, week, month, year).
 type Files []File
 
+// fileKey identifies a file. The filetype is part of the key because
+// files that have been redacted can share a path, or have no path at all,
+// and we still want to keep the time spent on each language apart.
+type fileKey struct {
+	path     string
+	filetype string
+}
+
 // createPathFileMap takes a slice of files and produces a map, where the
-// filepath is used as the key and the file itself serves as the value.
-func createPathFileMap(files Files) map[string]File {
-	pathFileMap := make(map[string]File)
+// filepath and filetype is used as the key and the file itself serves as the value.
+func createPathFileMap(files Files) map[fileKey]File {
+	pathFileMap := make(map[fileKey]File)
 	for _, f := range files {
-		pathFileMap[f.Path] = f
+		pathFileMap[fileKey{f.Path, f.Filetype}] = f
 	}
 	return pathFileMap
 }
@@ -51,18 +70,18 @@ func (a Files) merge(b Files) Files {
 	aFileMap := createPathFileMap(a)
 	bFileMap := createPathFileMap(b)
 
-	allPaths := make(map[string]struct{})
-	for path := range aFileMap {
-		allPaths[path] = struct{}{}
+	allKeys := make(map[fileKey]struct{})
+	for key := range aFileMap {
+		allKeys[key] = struct{}{}
 	}
-	for path := range bFileMap {
-		allPaths[path] = struct{}{}
+	for key := range bFileMap {
+		allKeys[key] = struct{}{}
 	}
 
-	mergedFiles := make([]File, 0, len(allPaths))
-	for path := range allPaths {
-		aFile := aFileMap[path]
-		bFile := bFileMap[path]
+	mergedFiles := make([]File, 0, len(allKeys))
+	for key := range allKeys {
+		aFile := aFileMap[key]
+		bFile := bFileMap[key]
 		mergedFiles = append(mergedFiles, aFile.merge(bFile))
 	}
 	return mergedFiles
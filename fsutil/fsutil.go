@@ -0,0 +1,54 @@
+// Package fsutil holds helpers for writing files
+// in a way that survives crashes and power loss.
+package fsutil
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// WriteFileAtomic writes the data to a temporary file next to the path, and
+// renames it into place once the data has been flushed to disk. The directory
+// is synced after the rename, which makes the new name durable too. A crash,
+// or a loss of power, therefore leaves either the previous file or the new
+// one, but never one that is empty or partially written. A rename alone
+// doesn't guarantee that: the file system may persist the rename before the
+// data it points to.
+func WriteFileAtomic(filePath string, data []byte, perm fs.FileMode) error {
+	tmpPath := filePath + ".tmp"
+	err := writeAndSync(tmpPath, data, perm)
+	if err == nil {
+		err = os.Rename(tmpPath, filePath)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return SyncDir(filepath.Dir(filePath))
+}
+
+// SyncDir flushes the entries of a directory to disk, which
+// makes files that were created or renamed within it durable.
+func SyncDir(dirPath string) error {
+	dir, err := os.Open(dirPath)
+	if err != nil {
+		return err
+	}
+	return errors.Join(dir.Sync(), dir.Close())
+}
+
+func writeAndSync(filePath string, data []byte, perm fs.FileMode) error {
+	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(data); err != nil {
+		return errors.Join(err, file.Close())
+	}
+	if err = file.Sync(); err != nil {
+		return errors.Join(err, file.Close())
+	}
+	return file.Close()
+}
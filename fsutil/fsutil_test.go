@@ -0,0 +1,42 @@
+package fsutil_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/viccon/pulse/fsutil"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "file.json")
+	for _, data := range []string{"first", "second"} {
+		if err := fsutil.WriteFileAtomic(filePath, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		bytes, err := os.ReadFile(filePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(bytes) != data {
+			t.Errorf("expected %q; got %q", data, bytes)
+		}
+	}
+
+	// The temporary file should have been renamed into place.
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected a single file in the directory; got %d", len(entries))
+	}
+
+	// A failed write should leave nothing behind.
+	if err = fsutil.WriteFileAtomic(filepath.Join(dir, "missing", "file.json"), nil, 0o600); err == nil {
+		t.Error("expected a write to a missing directory to fail")
+	}
+}
@@ -0,0 +1,187 @@
+package git
+
+import (
+	"path"
+	"sync"
+	"time"
+)
+
+// CacheStats describes how effective the repository cache has been.
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// HitRate returns the share of the repository lookups that were served from the cache.
+func (s CacheStats) HitRate() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// cachedDir is the project that a directory was resolved to.
+type cachedDir struct {
+	dir gitDir
+	// stamp is the file whose modification time decides if the entry is still
+	// valid. It's the .git file or config of the repository, and for projects
+	// that were found through a marker it's a .git that mustn't exist.
+	stamp   string
+	modTime time.Time
+	missing bool
+	// walked holds the directories that were searched for a .git on the way up to
+	// the project. The entry is stale if any of them gets a .git of its own.
+	walked []string
+}
+
+// cachedFile is the content of a file along with the time it was modified.
+type cachedFile struct {
+	modTime time.Time
+	content []byte
+}
+
+// cache maps directories to the repositories that they belong to. This lets
+// us avoid walking the file tree every time a buffer is opened. The entries
+// are invalidated when the modification time of the git files changes.
+type cache struct {
+	mu     sync.Mutex
+	dirs   map[string]cachedDir
+	files  map[string]cachedFile
+	hits   uint64
+	misses uint64
+}
+
+func newCache() *cache {
+	return &cache{
+		dirs:  make(map[string]cachedDir),
+		files: make(map[string]cachedFile),
+	}
+}
+
+// stampFor returns the file that is used to validate the cache entry for a project.
+func stampFor(dir gitDir) (string, bool) {
+	switch {
+	case dir.path == "":
+		return path.Join(dir.workTree, ".git"), true
+	case dir.path != dir.commonPath || dir.submodule:
+		return path.Join(dir.workTree, ".git"), false
+	default:
+		return path.Join(dir.commonPath, "config"), false
+	}
+}
+
+// walkedDirs returns the directories, from dir and up, that were searched for a
+// .git before the project was found. The project's own directory is left out,
+// as it's validated through the stamp. For projects that were found through a
+// marker, the search continued all the way to the root.
+func walkedDirs(dir string, project gitDir) []string {
+	var dirs []string
+	for ; dir != "/" && dir != "" && dir != "."; dir = path.Dir(dir) {
+		if dir != project.workTree {
+			dirs = append(dirs, dir)
+			continue
+		}
+		if project.path != "" {
+			break
+		}
+	}
+	return dirs
+}
+
+// valid checks if the entry can still be used. If a .git folder has been created
+// or removed, or if the git files have been modified, it has to be resolved again.
+// The same goes for a .git that has been created in one of the directories that
+// we passed on the way up to the project, e.g. by running git init in them.
+func (f FileParser) valid(entry cachedDir) bool {
+	for _, dir := range entry.walked {
+		if _, err := f.Reader.ModTime(path.Join(dir, ".git")); err == nil {
+			return false
+		}
+	}
+
+	modTime, err := f.Reader.ModTime(entry.stamp)
+	if entry.missing {
+		return err != nil
+	}
+	return err == nil && modTime.Equal(entry.modTime)
+}
+
+// lookup returns the project that the directory belongs to.
+func (f FileParser) lookup(dir string) (gitDir, error) {
+	if f.cache == nil {
+		return f.findGitFolder(dir)
+	}
+
+	f.cache.mu.Lock()
+	entry, ok := f.cache.dirs[dir]
+	f.cache.mu.Unlock()
+	if ok && f.valid(entry) {
+		f.cache.mu.Lock()
+		f.cache.hits++
+		f.cache.mu.Unlock()
+		return entry.dir, nil
+	}
+
+	project, err := f.findGitFolder(dir)
+	f.cache.mu.Lock()
+	defer f.cache.mu.Unlock()
+	f.cache.misses++
+	if err != nil {
+		delete(f.cache.dirs, dir)
+		return gitDir{}, err
+	}
+
+	stamp, missing := stampFor(project)
+	modTime, statErr := f.Reader.ModTime(stamp)
+	if statErr == nil || missing {
+		f.cache.dirs[dir] = cachedDir{
+			dir:     project,
+			stamp:   stamp,
+			modTime: modTime,
+			missing: missing,
+			walked:  walkedDirs(dir, project),
+		}
+	}
+	return project, nil
+}
+
+// readFile reads a file through the cache. The content is only read
+// from the underlying reader if the file has been modified.
+func (f FileParser) readFile(filename string) ([]byte, error) {
+	if f.cache == nil {
+		return f.Reader.ReadFile(filename)
+	}
+
+	modTime, err := f.Reader.ModTime(filename)
+	if err != nil {
+		return f.Reader.ReadFile(filename)
+	}
+
+	f.cache.mu.Lock()
+	file, ok := f.cache.files[filename]
+	f.cache.mu.Unlock()
+	if ok && file.modTime.Equal(modTime) {
+		return file.content, nil
+	}
+
+	content, err := f.Reader.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	f.cache.mu.Lock()
+	f.cache.files[filename] = cachedFile{modTime: modTime, content: content}
+	f.cache.mu.Unlock()
+	return content, nil
+}
+
+// CacheStats returns the number of repository lookups that were
+// served from the cache, and the number that had to walk the file tree.
+func (f FileParser) CacheStats() CacheStats {
+	if f.cache == nil {
+		return CacheStats{}
+	}
+	f.cache.mu.Lock()
+	defer f.cache.mu.Unlock()
+	return CacheStats{Hits: f.cache.hits, Misses: f.cache.misses}
+}
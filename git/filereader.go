@@ -4,6 +4,7 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 // filereader implements the Reader interface and adds functionality
@@ -25,6 +26,15 @@ func (f filereader) ReadFile(filename string) ([]byte, error) {
 	return os.ReadFile(filename)
 }
 
+// ModTime returns the modification time of the file.
+func (f filereader) ModTime(path string) (time.Time, error) {
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return fileInfo.ModTime(), nil
+}
+
 // IsFile is a wrapper around os.Stat. It returns the negation (!)
 // of calling IsDir() on the FileInfo that was returned by os.Stat.
 func (f filereader) IsFile(path string) bool {
@@ -1,19 +1,25 @@
 package git
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
 	"io/fs"
 	"path"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/viccon/pulse"
+	"github.com/viccon/pulse/ignore"
 )
 
 var (
-	bareRepoExp    = regexp.MustCompile("gitdir: (?P<GitDir>.*)/worktrees")
-	regularRepoExp = regexp.MustCompile(`url = .*(?:/|:)(?P<RepoName>[^/]*?)\.git`)
+	gitDirExp   = regexp.MustCompile("gitdir: (?P<GitDir>.*)")
+	bareRepoExp = regexp.MustCompile("(?P<GitDir>.*)/worktrees/[^/]+$")
+	headRefExp  = regexp.MustCompile(`ref: refs/heads/(?P<Branch>.+)`)
 )
 
 var (
@@ -22,23 +28,63 @@ var (
 	ErrReachedRoot       = errors.New("we reached the root without finding a .git file or folder")
 	ErrParseRepoPath     = errors.New("failed to parse repository path")
 	ErrParseBareRepoPath = errors.New("failed to parse bare repository path")
+	ErrParseGitFile      = errors.New("failed to parse the gitdir of the .git file")
 )
 
+// DefaultMarkers are the project markers that are used if none are configured.
+var DefaultMarkers = []string{".hg", ".svn", "go.mod", "package.json", ".pulse-project"}
+
 // Reader is an abstraction for the reader.
 type Reader interface {
 	Dir(string) string
 	ReadDir(string) ([]fs.DirEntry, error)
 	ReadFile(string) ([]byte, error)
 	IsFile(string) bool
+	ModTime(string) (time.Time, error)
+}
+
+// gitDir describes where the git metadata for a work tree is located.
+type gitDir struct {
+	// workTree is the directory that contains the .git file or folder.
+	workTree string
+	// path is the git directory of the work tree. It holds the HEAD file.
+	path string
+	// commonPath holds the configuration that is shared by every worktree.
+	commonPath string
+	// submodule is true if the work tree is a submodule of another repository.
+	submodule bool
 }
 
 type FileParser struct {
 	Reader Reader
+	// TicketExp is used to extract ticket ids from branch names. It's optional.
+	TicketExp *regexp.Regexp
+	// RecordSuperproject determines if files within a submodule should
+	// record the name of the repository that the submodule belongs to.
+	RecordSuperproject bool
+	// Remote is the name of the remote that is used to name the
+	// repository. It defaults to origin.
+	Remote string
+	// Markers are the names of the files and directories that identify the
+	// root of a project that isn't a git repository. They are only used if
+	// we don't find a .git file or folder.
+	Markers []string
+
+	cache *cache
 }
 
-// New creates a new FileParser.
+// New creates a new FileParser. It caches the repositories that
+// directories belong to, which is shared between copies of the parser.
 func New() FileParser {
-	return FileParser{filereader{}}
+	return FileParser{Reader: filereader{}, Markers: DefaultMarkers, cache: newCache()}
+}
+
+// extractTicket returns the first ticket id in the branch name.
+func (f FileParser) extractTicket(branch string) string {
+	if f.TicketExp == nil || branch == "" {
+		return ""
+	}
+	return f.TicketExp.FindString(branch)
 }
 
 // extractSubExp extracts a named subgroup from a regexp match.
@@ -51,67 +97,173 @@ func extractSubExp(re *regexp.Regexp, matches []string, subexp string) string {
 	return exp
 }
 
-// extractRepositoryName extracts the name of the repository from a .git file.
-func (f FileParser) extractBareRepositoryPath(filepath string) (string, error) {
-	fileContent, err := f.Reader.ReadFile(filepath)
+// readGitFile reads the .git file of a work tree. The file points to the git
+// directory of either a worktree or a submodule. Relative paths are resolved
+// from the directory that holds the .git file, which is how git writes them
+// for submodules, e.g. "gitdir: ../.git/modules/foo".
+func (f FileParser) readGitFile(workTree string) (gitDir, error) {
+	fileContent, err := f.Reader.ReadFile(path.Join(workTree, ".git"))
 	if err != nil {
-		return "", err
+		return gitDir{}, err
 	}
-	matches := bareRepoExp.FindStringSubmatch(string(fileContent))
 
-	if len(matches) == 0 {
-		return "", ErrParseBareRepoPath
+	gitDirMatches := gitDirExp.FindStringSubmatch(string(fileContent))
+	if len(gitDirMatches) == 0 {
+		return gitDir{}, ErrParseGitFile
+	}
+	gitDirPath := strings.TrimSpace(extractSubExp(gitDirExp, gitDirMatches, "GitDir"))
+	if !path.IsAbs(gitDirPath) {
+		gitDirPath = path.Join(workTree, gitDirPath)
 	}
 
-	return extractSubExp(bareRepoExp, matches, "GitDir"), nil
+	// When I work on projects with long-lived branches I use worktrees. The
+	// configuration of a worktree is shared with the bare repository.
+	if matches := bareRepoExp.FindStringSubmatch(gitDirPath); len(matches) > 0 {
+		barePath := extractSubExp(bareRepoExp, matches, "GitDir")
+		return gitDir{workTree: workTree, path: gitDirPath, commonPath: barePath}, nil
+	}
+
+	// Otherwise, it's a submodule which has a configuration of its own.
+	return gitDir{workTree: workTree, path: gitDirPath, commonPath: gitDirPath, submodule: true}, nil
+}
+
+// findGitFolder looks for the .git configuration of the directory. If the
+// directory isn't under source control, we'll fall back to the closest
+// directory that contains one of the project markers.
+func (f FileParser) findGitFolder(dir string) (gitDir, error) {
+	return f.findProject(dir, gitDir{})
 }
 
-// findGitFolder calls itself recursively until it finds a .git
-// configuration or reaches the root of the filesystem. It returns
-// the name of the repository by parsing the url of the origin.
-func (f FileParser) findGitFolder(dir string) (string, error) {
+// findProject calls itself recursively until it finds a .git configuration
+// or reaches the root of the filesystem. It returns the location of the work
+// tree and its git directories. The project is the closest directory with a
+// marker that we've passed on the way up. It's used if we reach the root.
+func (f FileParser) findProject(dir string, project gitDir) (gitDir, error) {
 	// Stop the recursion if we have reached the root.
-	if dir == "/" {
-		return "", ErrReachedRoot
+	if dir == "/" || dir == "" {
+		if project.workTree != "" {
+			return project, nil
+		}
+		return gitDir{}, ErrReachedRoot
 	}
 
 	// Read the directory entries.
 	entries, err := f.Reader.ReadDir(dir)
 	if err != nil {
-		return "", err
+		if project.workTree != "" {
+			return project, nil
+		}
+		return gitDir{}, err
 	}
 
 	// Check if any of the entries is the .git file/folder.
 	for _, e := range entries {
 		if e.Name() == ".git" {
-			// When I work on projects with long-lived branches I use worktrees. If that
-			// is the case the .git file will point to the path of the bare directory.
 			if !e.IsDir() {
-				return f.extractBareRepositoryPath(path.Join(dir, ".git"))
+				return f.readGitFile(dir)
+			}
+			gitPath := path.Join(dir, ".git")
+			return gitDir{workTree: dir, path: gitPath, commonPath: gitPath}, nil
+		}
+	}
+
+	if project.workTree == "" {
+		for _, e := range entries {
+			if slices.Contains(f.Markers, e.Name()) {
+				project = gitDir{workTree: dir}
+				break
 			}
-			return path.Join(dir, ".git"), nil
 		}
 	}
 
 	// If we didn't find the .git file/folder we'll continue up the path.
-	return f.findGitFolder(f.Reader.Dir(dir))
+	return f.findProject(f.Reader.Dir(dir), project)
 }
 
-// extractRepositoryName extracts the name of the repository by
-// looking at the url. This solves potential issues that could
-// occur if you were to clone a repository under a different name.
-func (f FileParser) extractRepositoryName(dirPath string) (string, error) {
-	fileContent, err := f.Reader.ReadFile(path.Join(dirPath, "config"))
+// extractBranch reads the HEAD of the git directory and returns the name of
+// the branch that is checked out. It returns an empty string if the HEAD is
+// detached, or if it can't be read.
+func (f FileParser) extractBranch(dirPath string) string {
+	// Projects that were found through a marker aren't under source control.
+	if dirPath == "" {
+		return ""
+	}
+
+	fileContent, err := f.readFile(path.Join(dirPath, "HEAD"))
 	if err != nil {
-		return "", err
+		return ""
 	}
 
-	matches := regularRepoExp.FindStringSubmatch(string(fileContent))
+	matches := headRefExp.FindStringSubmatch(string(fileContent))
 	if len(matches) == 0 {
-		return "", ErrParseRepoPath
+		return ""
+	}
+	return strings.TrimSpace(extractSubExp(headRefExp, matches, "Branch"))
+}
+
+// preferredRemote returns the remote that the repository should be named
+// after. The configured remote is tried first, followed by any other remote.
+func (f FileParser) preferredRemote(remotes []remote) (remote, bool) {
+	preferred := cmp.Or(f.Remote, defaultRemote)
+	for _, r := range remotes {
+		if r.name == preferred && nameFromURL(r.url) != "" {
+			return r, true
+		}
+	}
+	for _, r := range remotes {
+		if nameFromURL(r.url) != "" {
+			return r, true
+		}
+	}
+	return remote{}, false
+}
+
+// extractRepository extracts the name and identity of the repository by
+// looking at the url. This solves potential issues that could occur if
+// you were to clone a repository under a different name. Repositories
+// without remotes are named after their directory, and have no identity.
+func (f FileParser) extractRepository(dir gitDir) (string, string) {
+	// Projects that were found through a marker don't have a git configuration.
+	if dir.commonPath == "" {
+		return nameFromDirectory(dir), ""
+	}
+
+	fileContent, err := f.readFile(path.Join(dir.commonPath, "config"))
+	if err != nil {
+		return nameFromDirectory(dir), ""
+	}
+
+	r, ok := f.preferredRemote(parseRemotes(string(fileContent)))
+	if !ok {
+		return nameFromDirectory(dir), ""
+	}
+	return nameFromURL(r.url), idFromURL(r.url)
+}
+
+// extractSuperprojectName returns the name of the repository that contains the
+// submodule. It continues the search from the parent of the submodule's work
+// tree, which means that it also handles submodules that are nested.
+func (f FileParser) extractSuperprojectName(dir gitDir) string {
+	superproject, err := f.findGitFolder(f.Reader.Dir(dir.workTree))
+	if err != nil {
+		return ""
+	}
+	name, _ := f.extractRepository(superproject)
+	return name
+}
+
+// ignored reports whether the file matches the patterns in the
+// .pulseignore file that is located at the root of the work tree.
+func (f FileParser) ignored(dir gitDir, relativePath string) bool {
+	fileContent, err := f.readFile(path.Join(dir.workTree, ignore.Filename))
+	if err != nil {
+		return false
 	}
 
-	return extractSubExp(regularRepoExp, matches, "RepoName"), nil
+	// Malformed patterns are skipped. We don't want a typo in
+	// the file to stop us from tracking the repository altogether.
+	m, _ := ignore.Parse(string(fileContent))
+	return m.Match(relativePath)
 }
 
 // ParseFile returns a ParseFile struct from an absolute path. It will return an
@@ -128,26 +280,32 @@ func (f FileParser) ParseFile(absolutePath, filetype string) (pulse.GitFile, err
 	}
 
 	// Check if the file is under source control.
-	gitFolderPath, err := f.findGitFolder(f.Reader.Dir(absolutePath))
+	dir, err := f.lookup(f.Reader.Dir(absolutePath))
 	if err != nil {
 		return pulse.GitFile{}, err
 	}
 
-	repositoryName, err := f.extractRepositoryName(gitFolderPath)
-	if err != nil {
-		return pulse.GitFile{}, err
-	}
+	repositoryName, repositoryID := f.extractRepository(dir)
 
-	pathFromGitFolder := absolutePath[len(gitFolderPath)-len(".git"):]
-	path := fmt.Sprintf("%s/%s", repositoryName, pathFromGitFolder)
+	pathFromWorkTree := strings.TrimPrefix(absolutePath, dir.workTree+"/")
+	path := fmt.Sprintf("%s/%s", repositoryName, pathFromWorkTree)
 
 	// Tries to get the filetype from either the file extension or name.
 	filename := filepath.Base(absolutePath)
+	branch := f.extractBranch(dir.path)
 	gitFile := pulse.GitFile{
-		Name:       filename,
-		Filetype:   filetype,
-		Repository: repositoryName,
-		Path:       path,
+		Name:         filename,
+		Filetype:     filetype,
+		Repository:   repositoryName,
+		RepositoryID: repositoryID,
+		Branch:       branch,
+		Ticket:       f.extractTicket(branch),
+		Path:         path,
+	}
+
+	gitFile.Ignored = f.ignored(dir, pathFromWorkTree)
+	if dir.submodule && f.RecordSuperproject {
+		gitFile.Superproject = f.extractSuperprojectName(dir)
 	}
 
 	return gitFile, nil
@@ -1,11 +1,17 @@
 package git_test
 
 import (
+	"errors"
 	"fmt"
 	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/viccon/pulse"
 	"github.com/viccon/pulse/git"
 )
 
@@ -45,6 +51,13 @@ func (f *readerMock) IsFile(_ string) bool {
 	return true
 }
 
+func (f *readerMock) ModTime(filename string) (time.Time, error) {
+	if _, ok := f.FileContents[filename]; !ok {
+		return time.Time{}, fmt.Errorf("no file content for file: %s", filename)
+	}
+	return time.Time{}, nil
+}
+
 func (f *readerMock) Filename(path string) string {
 	s := strings.Split(path, "/")
 	return s[len(s)-1]
@@ -374,3 +387,623 @@ func TestPathInProject(t *testing.T) {
 		t.Errorf("GetRepositoryFromPath(%s) = %s; expected %s", path, got, expected)
 	}
 }
+
+func TestBranchFromHead(t *testing.T) {
+	t.Parallel()
+
+	gitConfigFile := `
+		[remote "origin"]
+			url = git@github.com:viccon/dotfiles.git
+			fetch = +refs/heads/*:refs/remotes/origin/*
+	`
+
+	directoryEntries := map[string][]fs.DirEntry{
+		"/Users/conner/code/dotfiles/editors": {
+			newFileEntry("init.lua", false),
+		},
+		"/Users/conner/code/dotfiles": {
+			newFileEntry("editors", true),
+			newFileEntry(".git", true),
+		},
+	}
+
+	fileSystemMock := readerMock{
+		DirectoryIndex: 0,
+		Directories: []string{
+			"/Users/conner/code/dotfiles/editors",
+			"/Users/conner/code/dotfiles",
+			"/Users/conner/code",
+			"/Users/conner",
+			"/Users",
+			"/",
+		},
+		Entries: directoryEntries,
+		FileContents: map[string][]byte{
+			"/Users/conner/code/dotfiles/.git/config": []byte(gitConfigFile),
+			"/Users/conner/code/dotfiles/.git/HEAD":   []byte("ref: refs/heads/feature/nvim-lsp\n"),
+		},
+	}
+
+	f := git.New()
+	f.Reader = &fileSystemMock
+
+	path := "/Users/conner/code/dotfiles/editors/init.lua"
+	file, err := f.ParseFile(path, "lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "feature/nvim-lsp"
+	if file.Branch != expected {
+		t.Errorf("ParseFile(%s).Branch = %s; expected %s", path, file.Branch, expected)
+	}
+}
+
+func TestBranchFromWorktreeHead(t *testing.T) {
+	t.Parallel()
+
+	gitFile := `gitdir: /Users/conner/code/ore-ui/.bare/worktrees/dev`
+	gitConfigFile := `
+		[core]
+			bare = true
+		[remote "origin"]
+			url = git@github.com:Mojang/ore-ui.git
+			fetch = +refs/heads/*:refs/remotes/origin/*
+	`
+
+	directoryEntries := map[string][]fs.DirEntry{
+		"/Users/conner/code/ore-ui/dev/src": {
+			newFileEntry("index.ts", false),
+		},
+		"/Users/conner/code/ore-ui/dev": {
+			newFileEntry("src", true),
+			newFileEntry(".git", false),
+		},
+	}
+
+	fileSystemMock := readerMock{
+		DirectoryIndex: 0,
+		Directories: []string{
+			"/Users/conner/code/ore-ui/dev/src",
+			"/Users/conner/code/ore-ui/dev",
+			"/Users/conner/code/ore-ui",
+			"/Users/conner/code",
+			"/Users/conner",
+			"/Users",
+			"/",
+		},
+		Entries: directoryEntries,
+		FileContents: map[string][]byte{
+			"/Users/conner/code/ore-ui/dev/.git":                 []byte(gitFile),
+			"/Users/conner/code/ore-ui/.bare/config":             []byte(gitConfigFile),
+			"/Users/conner/code/ore-ui/.bare/HEAD":               []byte("ref: refs/heads/main\n"),
+			"/Users/conner/code/ore-ui/.bare/worktrees/dev/HEAD": []byte("ref: refs/heads/dev\n"),
+		},
+	}
+
+	f := git.New()
+	f.Reader = &fileSystemMock
+
+	path := "/Users/conner/code/ore-ui/dev/src/index.ts"
+	file, err := f.ParseFile(path, "typescript")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each worktree has its own HEAD, which is what we expect the branch to be read from.
+	if file.Branch != "dev" {
+		t.Errorf("ParseFile(%s).Branch = %s; expected %s", path, file.Branch, "dev")
+	}
+	if file.Path != "ore-ui/src/index.ts" {
+		t.Errorf("ParseFile(%s).Path = %s; expected %s", path, file.Path, "ore-ui/src/index.ts")
+	}
+}
+
+func TestTicketFromBranch(t *testing.T) {
+	t.Parallel()
+
+	gitConfigFile := `
+		[remote "origin"]
+			url = git@github.com:viccon/pulse.git
+	`
+
+	fileSystemMock := readerMock{
+		DirectoryIndex: 0,
+		Directories: []string{
+			"/Users/conner/code/pulse",
+			"/Users/conner/code",
+			"/Users/conner",
+			"/Users",
+			"/",
+		},
+		Entries: map[string][]fs.DirEntry{
+			"/Users/conner/code/pulse": {
+				newFileEntry("main.go", false),
+				newFileEntry(".git", true),
+			},
+		},
+		FileContents: map[string][]byte{
+			"/Users/conner/code/pulse/.git/config": []byte(gitConfigFile),
+			"/Users/conner/code/pulse/.git/HEAD":   []byte("ref: refs/heads/feature/PULSE-123-outbox\n"),
+		},
+	}
+
+	f := git.New()
+	f.Reader = &fileSystemMock
+	f.TicketExp = regexp.MustCompile(`[A-Z]+-\d+`)
+
+	file, err := f.ParseFile("/Users/conner/code/pulse/main.go", "go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Ticket != "PULSE-123" {
+		t.Errorf("expected the ticket to be PULSE-123; got %s", file.Ticket)
+	}
+}
+
+func TestSubmodule(t *testing.T) {
+	t.Parallel()
+
+	superprojectConfig := `
+		[remote "origin"]
+			url = git@github.com:viccon/dotfiles.git
+	`
+	submoduleConfig := `
+		[core]
+			worktree = ../../../../editors/nvim
+		[remote "origin"]
+			url = git@github.com:viccon/nvim.git
+	`
+
+	fileSystemMock := readerMock{
+		DirectoryIndex: 0,
+		Directories: []string{
+			"/Users/conner/code/dotfiles/editors/nvim/lua",
+			"/Users/conner/code/dotfiles/editors/nvim",
+			"/Users/conner/code/dotfiles/editors",
+			"/Users/conner/code/dotfiles",
+			"/Users/conner/code",
+			"/Users/conner",
+			"/Users",
+			"/",
+		},
+		Entries: map[string][]fs.DirEntry{
+			"/Users/conner/code/dotfiles/editors/nvim/lua": {
+				newFileEntry("init.lua", false),
+			},
+			"/Users/conner/code/dotfiles/editors/nvim": {
+				newFileEntry("lua", true),
+				newFileEntry(".git", false),
+			},
+			"/Users/conner/code/dotfiles/editors": {
+				newFileEntry("nvim", true),
+			},
+			"/Users/conner/code/dotfiles": {
+				newFileEntry("editors", true),
+				newFileEntry(".git", true),
+			},
+		},
+		FileContents: map[string][]byte{
+			"/Users/conner/code/dotfiles/editors/nvim/.git":                []byte("gitdir: ../../.git/modules/editors/nvim\n"),
+			"/Users/conner/code/dotfiles/.git/config":                      []byte(superprojectConfig),
+			"/Users/conner/code/dotfiles/.git/modules/editors/nvim/config": []byte(submoduleConfig),
+			"/Users/conner/code/dotfiles/.git/modules/editors/nvim/HEAD":   []byte("ref: refs/heads/main\n"),
+		},
+	}
+
+	f := git.New()
+	f.Reader = &fileSystemMock
+	f.RecordSuperproject = true
+
+	path := "/Users/conner/code/dotfiles/editors/nvim/lua/init.lua"
+	file, err := f.ParseFile(path, "lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The time should be attributed to the submodule's own repository.
+	if file.Repository != "nvim" {
+		t.Errorf("ParseFile(%s).Repository = %s; expected %s", path, file.Repository, "nvim")
+	}
+	if file.Path != "nvim/lua/init.lua" {
+		t.Errorf("ParseFile(%s).Path = %s; expected %s", path, file.Path, "nvim/lua/init.lua")
+	}
+	if file.Branch != "main" {
+		t.Errorf("ParseFile(%s).Branch = %s; expected %s", path, file.Branch, "main")
+	}
+	if file.Superproject != "dotfiles" {
+		t.Errorf("ParseFile(%s).Superproject = %s; expected %s", path, file.Superproject, "dotfiles")
+	}
+}
+
+func TestRepositoryNameFallbacks(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		remote   string
+		config   string
+		expected string
+	}{
+		{
+			name: "prefers origin",
+			config: `
+				[remote "upstream"]
+					url = git@github.com:neovim/neovim.git
+				[remote "origin"]
+					url = git@github.com:viccon/pulse.git
+			`,
+			expected: "pulse",
+		},
+		{
+			name:   "prefers the configured remote",
+			remote: "upstream",
+			config: `
+				[remote "origin"]
+					url = git@github.com:viccon/pulse-fork.git
+				[remote "upstream"]
+					url = git@github.com:viccon/pulse.git
+			`,
+			expected: "pulse",
+		},
+		{
+			name: "falls back to any remote",
+			config: `
+				[remote "github"]
+					url = https://github.com/viccon/pulse
+			`,
+			expected: "pulse",
+		},
+		{
+			name: "file remote",
+			config: `
+				[remote "origin"]
+					url = file:///srv/git/pulse.git/
+			`,
+			expected: "pulse",
+		},
+		{
+			name: "no remotes",
+			config: `
+				[core]
+					bare = false
+			`,
+			expected: "scratch",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			fileSystemMock := readerMock{
+				DirectoryIndex: 0,
+				Directories:    []string{"/Users/conner/code/scratch", "/Users/conner/code", "/"},
+				Entries: map[string][]fs.DirEntry{
+					"/Users/conner/code/scratch": {
+						newFileEntry("main.go", false),
+						newFileEntry(".git", true),
+					},
+				},
+				FileContents: map[string][]byte{
+					"/Users/conner/code/scratch/.git/config": []byte(tt.config),
+				},
+			}
+
+			f := git.New()
+			f.Reader = &fileSystemMock
+			f.Remote = tt.remote
+
+			file, err := f.ParseFile("/Users/conner/code/scratch/main.go", "go")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if file.Repository != tt.expected {
+				t.Errorf("expected the repository to be %s; got %s", tt.expected, file.Repository)
+			}
+			if file.Path != tt.expected+"/main.go" {
+				t.Errorf("expected the path to be %s/main.go; got %s", tt.expected, file.Path)
+			}
+		})
+	}
+}
+
+func TestRepositoryID(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		url  string
+		id   string
+		name string
+	}{
+		{url: "git@github.com:us/api.git", id: "github.com/us/api", name: "api"},
+		{url: "https://gitlab.com/client/api", id: "gitlab.com/client/api", name: "api"},
+		{url: "ssh://git@GitLab.com:2222/client/backend/api.git", id: "gitlab.com/client/backend/api", name: "api"},
+		{url: "file:///srv/git/api.git", id: "", name: "api"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			t.Parallel()
+
+			fileSystemMock := readerMock{
+				DirectoryIndex: 0,
+				Directories:    []string{"/Users/conner/code/api", "/Users/conner/code", "/"},
+				Entries: map[string][]fs.DirEntry{
+					"/Users/conner/code/api": {
+						newFileEntry("main.go", false),
+						newFileEntry(".git", true),
+					},
+				},
+				FileContents: map[string][]byte{
+					"/Users/conner/code/api/.git/config": []byte(fmt.Sprintf("[remote \"origin\"]\n\turl = %s\n", tt.url)),
+				},
+			}
+
+			f := git.New()
+			f.Reader = &fileSystemMock
+
+			file, err := f.ParseFile("/Users/conner/code/api/main.go", "go")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if file.RepositoryID != tt.id {
+				t.Errorf("expected the repository id to be %q; got %q", tt.id, file.RepositoryID)
+			}
+			if file.Repository != tt.name {
+				t.Errorf("expected the repository name to be %q; got %q", tt.name, file.Repository)
+			}
+		})
+	}
+}
+
+func TestProjectMarkers(t *testing.T) {
+	t.Parallel()
+
+	fileSystemMock := readerMock{
+		DirectoryIndex: 0,
+		Directories: []string{
+			"/Users/conner/scratch/cmd",
+			"/Users/conner/scratch",
+			"/Users/conner",
+			"/Users",
+			"/",
+		},
+		Entries: map[string][]fs.DirEntry{
+			"/Users/conner/scratch/cmd": {
+				newFileEntry("main.go", false),
+			},
+			"/Users/conner/scratch": {
+				newFileEntry("cmd", true),
+				newFileEntry("go.mod", false),
+			},
+			"/Users/conner": {
+				newFileEntry("scratch", true),
+				newFileEntry(".pulse-project", false),
+			},
+			"/Users": {
+				newFileEntry("conner", true),
+			},
+		},
+		FileContents: map[string][]byte{},
+	}
+
+	f := git.New()
+	f.Reader = &fileSystemMock
+
+	path := "/Users/conner/scratch/cmd/main.go"
+	file, err := f.ParseFile(path, "go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The closest marker should determine the root of the project.
+	if file.Repository != "scratch" {
+		t.Errorf("ParseFile(%s).Repository = %s; expected %s", path, file.Repository, "scratch")
+	}
+	if file.Path != "scratch/cmd/main.go" {
+		t.Errorf("ParseFile(%s).Path = %s; expected %s", path, file.Path, "scratch/cmd/main.go")
+	}
+	if file.Branch != "" || file.RepositoryID != "" {
+		t.Errorf("expected a project without source control to have no branch or id; got %+v", file)
+	}
+}
+
+func TestProjectMarkersDisabled(t *testing.T) {
+	t.Parallel()
+
+	fileSystemMock := readerMock{
+		DirectoryIndex: 0,
+		Directories:    []string{"/Users/conner/scratch", "/Users/conner", "/Users", "/"},
+		Entries: map[string][]fs.DirEntry{
+			"/Users/conner/scratch": {newFileEntry("main.go", false), newFileEntry("go.mod", false)},
+			"/Users/conner":         {newFileEntry("scratch", true)},
+			"/Users":                {newFileEntry("conner", true)},
+		},
+	}
+
+	f := git.New()
+	f.Reader = &fileSystemMock
+	f.Markers = nil
+
+	_, err := f.ParseFile("/Users/conner/scratch/main.go", "go")
+	if !errors.Is(err, git.ErrReachedRoot) {
+		t.Errorf("expected ErrReachedRoot; got %v", err)
+	}
+}
+
+func TestCachedLookups(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	gitDir := filepath.Join(root, ".git")
+	srcDir := filepath.Join(root, "src")
+	for _, dir := range []string{gitDir, srcDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile := func(name, content string, modTime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(name, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(name, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	modTime := time.Now().Add(-time.Hour)
+	writeFile(filepath.Join(gitDir, "config"), "[remote \"origin\"]\n\turl = git@github.com:viccon/pulse.git\n", modTime)
+	writeFile(filepath.Join(gitDir, "HEAD"), "ref: refs/heads/main\n", modTime)
+	writeFile(filepath.Join(srcDir, "main.go"), "package main\n", modTime)
+
+	f := git.New()
+	path := filepath.Join(srcDir, "main.go")
+	for range 3 {
+		file, err := f.ParseFile(path, "go")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if file.Repository != "pulse" || file.Branch != "main" {
+			t.Fatalf("unexpected file: %+v", file)
+		}
+	}
+	if stats := f.CacheStats(); stats.Hits != 2 || stats.Misses != 1 {
+		t.Errorf("expected 2 hits and 1 miss; got %+v", stats)
+	}
+
+	// Switching branches modifies the HEAD, which should be read again.
+	writeFile(filepath.Join(gitDir, "HEAD"), "ref: refs/heads/outbox\n", modTime.Add(time.Minute))
+	file, err := f.ParseFile(path, "go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Branch != "outbox" {
+		t.Errorf("expected the branch to be outbox; got %s", file.Branch)
+	}
+
+	// Modifying the config invalidates the repository.
+	writeFile(filepath.Join(gitDir, "config"), "[remote \"origin\"]\n\turl = git@github.com:viccon/sturdyc.git\n", modTime.Add(time.Minute))
+	file, err = f.ParseFile(path, "go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Repository != "sturdyc" {
+		t.Errorf("expected the repository to be sturdyc; got %s", file.Repository)
+	}
+	if stats := f.CacheStats(); stats.Hits != 3 || stats.Misses != 2 {
+		t.Errorf("expected 3 hits and 2 misses; got %+v", stats)
+	}
+}
+
+func TestCachedLookupsAreInvalidatedByNewRepositories(t *testing.T) {
+	t.Parallel()
+
+	reader := git.NewMemoryReader()
+	reader.AddRepository("/code/monorepo", "git@github.com:viccon/monorepo.git", "main")
+	reader.WriteFile("/code/monorepo/services/api/main.go", "")
+	reader.WriteFile("/scratch/tool/go.mod", "")
+	reader.WriteFile("/scratch/tool/main.go", "")
+
+	f := git.New()
+	f.Reader = reader
+
+	tests := []struct {
+		path     string
+		before   string
+		init     func()
+		expected string
+	}{
+		{
+			// A repository is created in a subdirectory of a cached repository.
+			path:     "/code/monorepo/services/api/main.go",
+			before:   "monorepo",
+			init:     func() { reader.AddRepository("/code/monorepo/services/api", "git@github.com:viccon/api.git", "main") },
+			expected: "api",
+		},
+		{
+			// A repository is created in a parent of a project that was found through a marker.
+			path:     "/scratch/tool/main.go",
+			before:   "tool",
+			init:     func() { reader.AddRepository("/scratch", "git@github.com:viccon/scratch.git", "main") },
+			expected: "scratch",
+		},
+	}
+
+	for _, tt := range tests {
+		for range 2 {
+			file, err := f.ParseFile(tt.path, "go")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if file.Repository != tt.before {
+				t.Fatalf("expected the repository of %s to be %s; got %s", tt.path, tt.before, file.Repository)
+			}
+		}
+
+		tt.init()
+		file, err := f.ParseFile(tt.path, "go")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if file.Repository != tt.expected {
+			t.Errorf("expected the repository of %s to be %s; got %s", tt.path, tt.expected, file.Repository)
+		}
+	}
+}
+
+func TestMemoryReader(t *testing.T) {
+	t.Parallel()
+
+	reader := git.NewMemoryReader()
+	reader.AddBareRepository("/code/ore-ui/.bare", "git@github.com:Mojang/ore-ui.git")
+	reader.AddWorktree("/code/ore-ui/.bare", "/code/ore-ui/dev", "dev")
+	reader.WriteFile("/code/ore-ui/dev/src/index.ts", "")
+	reader.AddRepository("/code/dotfiles", "git@github.com:viccon/dotfiles.git", "main")
+	reader.AddSubmodule("/code/dotfiles", "editors/nvim", "git@github.com:viccon/nvim.git", "lua")
+	reader.WriteFile("/code/dotfiles/editors/nvim/init.lua", "")
+
+	f := git.New()
+	f.Reader = reader
+	f.RecordSuperproject = true
+
+	tests := []struct {
+		path     string
+		expected pulse.GitFile
+	}{
+		{
+			path: "/code/ore-ui/dev/src/index.ts",
+			expected: pulse.GitFile{
+				Name:         "index.ts",
+				Filetype:     "typescript",
+				Repository:   "ore-ui",
+				RepositoryID: "github.com/Mojang/ore-ui",
+				Branch:       "dev",
+				Path:         "ore-ui/src/index.ts",
+			},
+		},
+		{
+			path: "/code/dotfiles/editors/nvim/init.lua",
+			expected: pulse.GitFile{
+				Name:         "init.lua",
+				Filetype:     "lua",
+				Repository:   "nvim",
+				RepositoryID: "github.com/viccon/nvim",
+				Branch:       "lua",
+				Path:         "nvim/init.lua",
+				Superproject: "dotfiles",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		file, err := f.ParseFile(tt.path, tt.expected.Filetype)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if file != tt.expected {
+			t.Errorf("ParseFile(%s) = %+v; expected %+v", tt.path, file, tt.expected)
+		}
+	}
+}
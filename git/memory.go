@@ -0,0 +1,200 @@
+package git
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryFile is a file that is stored by the MemoryReader.
+type memoryFile struct {
+	content []byte
+	modTime time.Time
+}
+
+// memoryEntry implements the fs.DirEntry interface for the MemoryReader.
+type memoryEntry struct {
+	name  string
+	isDir bool
+}
+
+func (e memoryEntry) Name() string { return e.name }
+func (e memoryEntry) IsDir() bool  { return e.isDir }
+
+func (e memoryEntry) Type() fs.FileMode {
+	if e.isDir {
+		return fs.ModeDir
+	}
+	return 0
+}
+
+func (e memoryEntry) Info() (fs.FileInfo, error) {
+	return nil, fs.ErrInvalid
+}
+
+// MemoryReader implements the Reader interface with an in-memory file tree.
+// It's used to declare repositories, worktrees and submodules in tests,
+// without having to commit a .git directory.
+type MemoryReader struct {
+	mu    sync.Mutex
+	files map[string]memoryFile
+	dirs  map[string]map[string]bool
+	// writes is used to give every write a unique modification time.
+	writes int64
+}
+
+// NewMemoryReader creates a new MemoryReader with an empty file tree.
+func NewMemoryReader() *MemoryReader {
+	return &MemoryReader{
+		files: make(map[string]memoryFile),
+		dirs:  map[string]map[string]bool{"/": {}},
+	}
+}
+
+// addDir adds the directory, along with all of its parents, to the tree.
+func (m *MemoryReader) addDir(dir string) {
+	for dir != "/" {
+		if _, ok := m.dirs[dir]; !ok {
+			m.dirs[dir] = make(map[string]bool)
+		}
+		parent := path.Dir(dir)
+		m.addEntry(parent, path.Base(dir), true)
+		dir = parent
+	}
+}
+
+func (m *MemoryReader) addEntry(dir, name string, isDir bool) {
+	if _, ok := m.dirs[dir]; !ok {
+		m.dirs[dir] = make(map[string]bool)
+	}
+	m.dirs[dir][name] = isDir
+}
+
+// WriteFile creates or overwrites a file. The file gets a new modification time.
+func (m *MemoryReader) WriteFile(filename, content string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	filename = path.Clean(filename)
+	m.addDir(path.Dir(filename))
+	m.addEntry(path.Dir(filename), path.Base(filename), false)
+	m.writes++
+	m.files[filename] = memoryFile{
+		content: []byte(content),
+		modTime: time.Unix(0, 0).Add(time.Duration(m.writes) * time.Second),
+	}
+}
+
+// gitConfig returns the configuration of a repository with an origin remote.
+func gitConfig(remoteURL string) string {
+	if remoteURL == "" {
+		return "[core]\n\trepositoryformatversion = 0\n"
+	}
+	return fmt.Sprintf("[core]\n\trepositoryformatversion = 0\n[remote \"origin\"]\n\turl = %s\n", remoteURL)
+}
+
+// headRef returns the content of a HEAD file that has the branch checked out.
+func headRef(branch string) string {
+	return fmt.Sprintf("ref: refs/heads/%s\n", branch)
+}
+
+// AddRepository declares a regular repository with a .git folder in the work tree.
+// The remote url is optional, and the branch is the one that is checked out.
+func (m *MemoryReader) AddRepository(workTree, remoteURL, branch string) {
+	m.WriteFile(path.Join(workTree, ".git", "config"), gitConfig(remoteURL))
+	m.WriteFile(path.Join(workTree, ".git", "HEAD"), headRef(branch))
+}
+
+// AddBareRepository declares a bare repository that worktrees can be added to.
+func (m *MemoryReader) AddBareRepository(dir, remoteURL string) {
+	m.WriteFile(path.Join(dir, "config"), gitConfig(remoteURL))
+	m.WriteFile(path.Join(dir, "HEAD"), headRef("main"))
+}
+
+// AddWorktree declares a worktree of a bare repository, with the branch checked out.
+func (m *MemoryReader) AddWorktree(bareDir, workTree, branch string) {
+	gitDir := path.Join(bareDir, "worktrees", path.Base(workTree))
+	m.WriteFile(path.Join(gitDir, "HEAD"), headRef(branch))
+	m.WriteFile(path.Join(workTree, ".git"), fmt.Sprintf("gitdir: %s\n", gitDir))
+}
+
+// AddSubmodule declares a submodule at the relative path within the superproject.
+// Like git, the .git file of the submodule points to a relative git directory.
+func (m *MemoryReader) AddSubmodule(superWorkTree, relativePath, remoteURL, branch string) {
+	workTree := path.Join(superWorkTree, relativePath)
+	gitDir := path.Join(superWorkTree, ".git", "modules", relativePath)
+	m.WriteFile(path.Join(gitDir, "config"), gitConfig(remoteURL))
+	m.WriteFile(path.Join(gitDir, "HEAD"), headRef(branch))
+
+	relativeGitDir, err := filepath.Rel(workTree, gitDir)
+	if err != nil {
+		panic(err)
+	}
+	m.WriteFile(path.Join(workTree, ".git"), fmt.Sprintf("gitdir: %s\n", filepath.ToSlash(relativeGitDir)))
+}
+
+// Dir is a wrapper around path.Dir.
+func (m *MemoryReader) Dir(p string) string {
+	return path.Dir(p)
+}
+
+// ReadDir returns the entries of the directory, sorted by name.
+func (m *MemoryReader) ReadDir(dir string) ([]fs.DirEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	children, ok := m.dirs[path.Clean(dir)]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+
+	entries := make([]fs.DirEntry, 0, len(children))
+	for name, isDir := range children {
+		entries = append(entries, memoryEntry{name: name, isDir: isDir})
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return entries, nil
+}
+
+// ReadFile returns the content of the file.
+func (m *MemoryReader) ReadFile(filename string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	file, ok := m.files[path.Clean(filename)]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return slices.Clone(file.content), nil
+}
+
+// IsFile returns true if the path is a file rather than a directory.
+func (m *MemoryReader) IsFile(p string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.files[path.Clean(p)]
+	return ok
+}
+
+// ModTime returns the time that the file was last written.
+func (m *MemoryReader) ModTime(p string) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p = path.Clean(p)
+	if file, ok := m.files[p]; ok {
+		return file.modTime, nil
+	}
+	if _, ok := m.dirs[p]; ok {
+		return time.Unix(0, 0), nil
+	}
+	return time.Time{}, fs.ErrNotExist
+}
@@ -0,0 +1,112 @@
+package git
+
+import (
+	"bufio"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// defaultRemote is the remote that we prefer when a repository has several.
+const defaultRemote = "origin"
+
+var (
+	remoteSectionExp = regexp.MustCompile(`^\[remote "(?P<Remote>[^"]+)"\]$`)
+	remoteURLExp     = regexp.MustCompile(`^url\s*=\s*(?P<URL>.+)$`)
+)
+
+// remote is a remote that is declared in the configuration of a repository.
+type remote struct {
+	name string
+	url  string
+}
+
+// parseRemotes returns the remotes in a git configuration file,
+// in the order that they were declared.
+func parseRemotes(config string) []remote {
+	remotes := make([]remote, 0)
+	current := ""
+	scanner := bufio.NewScanner(strings.NewReader(config))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			current = ""
+			if matches := remoteSectionExp.FindStringSubmatch(line); len(matches) > 0 {
+				current = extractSubExp(remoteSectionExp, matches, "Remote")
+			}
+			continue
+		}
+
+		if current == "" {
+			continue
+		}
+		if matches := remoteURLExp.FindStringSubmatch(line); len(matches) > 0 {
+			remoteURL := strings.TrimSpace(extractSubExp(remoteURLExp, matches, "URL"))
+			remotes = append(remotes, remote{name: current, url: remoteURL})
+		}
+	}
+	return remotes
+}
+
+// parseURL splits a remote url into its host and the path of the repository,
+// e.g. github.com and viccon/pulse. It handles ssh, scp-like and http urls.
+// Local paths and file:// urls don't have a host.
+func parseURL(rawURL string) (string, string) {
+	rawURL = strings.TrimSpace(rawURL)
+	var host, repoPath string
+	if u, err := url.Parse(rawURL); err == nil && strings.Contains(rawURL, "://") {
+		host, repoPath = u.Hostname(), u.Path
+	} else if i := strings.Index(rawURL, ":"); i > 0 && !strings.Contains(rawURL[:i], "/") {
+		// scp-like syntax, e.g. git@github.com:viccon/pulse.git
+		host, repoPath = rawURL[:i], rawURL[i+1:]
+		if at := strings.LastIndex(host, "@"); at >= 0 {
+			host = host[at+1:]
+		}
+	} else {
+		repoPath = rawURL
+	}
+
+	repoPath = strings.Trim(repoPath, "/")
+	repoPath = strings.TrimSuffix(repoPath, ".git")
+	return strings.ToLower(host), repoPath
+}
+
+// nameFromURL extracts the name of a repository from a remote url. It handles
+// both ssh and http urls, as well as local paths and file:// urls, with or
+// without the .git suffix.
+func nameFromURL(rawURL string) string {
+	_, repoPath := parseURL(rawURL)
+	if repoPath == "" {
+		return ""
+	}
+	return path.Base(repoPath)
+}
+
+// idFromURL returns the fully qualified identity of a repository, e.g.
+// github.com/viccon/pulse. Repositories with the same name can be told
+// apart by their host and owner. It returns an empty string for remotes
+// that don't have a host.
+func idFromURL(rawURL string) string {
+	host, repoPath := parseURL(rawURL)
+	if host == "" || repoPath == "" {
+		return ""
+	}
+	return host + "/" + repoPath
+}
+
+// nameFromDirectory is used for repositories without any remotes. It returns
+// the name of the repository's top-level directory. For worktrees, that is the
+// directory of the bare repository rather than the one of the worktree.
+func nameFromDirectory(dir gitDir) string {
+	if dir.path == dir.commonPath {
+		return path.Base(dir.workTree)
+	}
+
+	base := path.Base(dir.commonPath)
+	// The bare repository is often cloned into a hidden directory, e.g. pulse/.bare
+	if strings.HasPrefix(base, ".") {
+		return path.Base(path.Dir(dir.commonPath))
+	}
+	return strings.TrimSuffix(base, ".git")
+}
@@ -0,0 +1,129 @@
+// Package ignore matches paths against glob patterns, which is
+// used to prevent repositories and files from being tracked.
+package ignore
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Filename is the name of the file that holds the ignore
+// patterns that have been checked into a repository.
+const Filename = ".pulseignore"
+
+// pattern is a glob that has been split into its path segments.
+type pattern []string
+
+// Matcher reports whether paths match any of its patterns. The patterns work
+// like the ones in a .gitignore file. A pattern without a slash matches a file
+// or directory with that name at any depth, while a pattern with a slash is
+// matched from the root. A double asterisk matches any number of directories.
+// If a directory matches, so does everything within it.
+type Matcher struct {
+	patterns []pattern
+}
+
+// New compiles the patterns. Patterns that are malformed are left out
+// of the matcher, and are reported through the returned error.
+func New(patterns []string) (Matcher, error) {
+	var m Matcher
+	var errs []error
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if p == "" || strings.HasPrefix(p, "#") {
+			continue
+		}
+
+		compiled, err := compile(p)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		m.patterns = append(m.patterns, compiled)
+	}
+	return m, errors.Join(errs...)
+}
+
+// Parse compiles the patterns of an ignore file, which has one pattern
+// per line. Empty lines and lines that start with a # are skipped.
+func Parse(content string) (Matcher, error) {
+	return New(strings.Split(content, "\n"))
+}
+
+// anchored reports whether the pattern is matched from the root,
+// which is the case for every pattern that contains a slash.
+func anchored(p string) bool {
+	return strings.Contains(strings.TrimSuffix(strings.TrimSpace(p), "/"), "/")
+}
+
+// SplitAnchored separates the patterns that are matched from the root from
+// the ones that match a name at any depth.
+func SplitAnchored(patterns []string) ([]string, []string) {
+	var names, rooted []string
+	for _, p := range patterns {
+		if anchored(p) {
+			rooted = append(rooted, p)
+			continue
+		}
+		names = append(names, p)
+	}
+	return names, rooted
+}
+
+func compile(p string) (pattern, error) {
+	isAnchored := anchored(p)
+	p = strings.Trim(p, "/")
+
+	segments := strings.Split(p, "/")
+	for _, segment := range segments {
+		if _, err := path.Match(segment, ""); err != nil {
+			return nil, fmt.Errorf("invalid ignore pattern %q: %w", p, err)
+		}
+	}
+
+	if !isAnchored {
+		segments = append(pattern{"**"}, segments...)
+	}
+	return segments, nil
+}
+
+// matchSegments reports whether the path segments match the pattern.
+func matchSegments(p pattern, segments []string) bool {
+	if len(p) == 0 {
+		return len(segments) == 0
+	}
+
+	if p[0] == "**" {
+		if matchSegments(p[1:], segments) {
+			return true
+		}
+		return len(segments) > 0 && matchSegments(p, segments[1:])
+	}
+
+	if len(segments) == 0 {
+		return false
+	}
+	ok, _ := path.Match(p[0], segments[0])
+	return ok && matchSegments(p[1:], segments[1:])
+}
+
+// Match reports whether the path, or any of its parent directories,
+// matches one of the patterns. The path should be relative to the root.
+func (m Matcher) Match(relativePath string) bool {
+	segments := strings.Split(strings.Trim(relativePath, "/"), "/")
+	for _, p := range m.patterns {
+		for i := 1; i <= len(segments); i++ {
+			if matchSegments(p, segments[:i]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Empty reports whether the matcher is without any patterns.
+func (m Matcher) Empty() bool {
+	return len(m.patterns) == 0
+}
@@ -0,0 +1,68 @@
+package ignore_test
+
+import (
+	"testing"
+
+	"github.com/viccon/pulse/ignore"
+)
+
+func TestMatch(t *testing.T) {
+	t.Parallel()
+
+	m, err := ignore.New([]string{"vendor/**", "node_modules", "*.env", "/docs/private", "**/testdata/*.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{path: "vendor/github.com/foo/foo.go", expected: true},
+		{path: "cmd/vendor/foo.go", expected: false},
+		{path: "web/node_modules/react/index.js", expected: true},
+		{path: "node_modules", expected: true},
+		{path: ".env", expected: true},
+		{path: "config/prod.env", expected: true},
+		{path: "config/prod.envrc", expected: false},
+		{path: "docs/private/notes.md", expected: true},
+		{path: "src/docs/private/notes.md", expected: false},
+		{path: "logdb/testdata/segment.json", expected: true},
+		{path: "testdata/segment.json", expected: true},
+		{path: "main.go", expected: false},
+	}
+
+	for _, tt := range tests {
+		if got := m.Match(tt.path); got != tt.expected {
+			t.Errorf("Match(%s) = %v; expected %v", tt.path, got, tt.expected)
+		}
+	}
+}
+
+func TestSplitAnchored(t *testing.T) {
+	t.Parallel()
+
+	names, rooted := ignore.SplitAnchored([]string{"journal", "github.com/acme/*", "dotfiles/", "/scratch"})
+	if len(names) != 2 || names[0] != "journal" || names[1] != "dotfiles/" {
+		t.Errorf("expected journal and dotfiles/ to match names; got %v", names)
+	}
+	if len(rooted) != 2 || rooted[0] != "github.com/acme/*" || rooted[1] != "/scratch" {
+		t.Errorf("expected github.com/acme/* and /scratch to be anchored; got %v", rooted)
+	}
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	content := "# Generated code\n\n*.pb.go\n[invalid\n"
+	m, err := ignore.Parse(content)
+	if err == nil {
+		t.Error("expected the malformed pattern to be reported")
+	}
+	if !m.Match("api/api.pb.go") {
+		t.Error("expected the valid patterns to be used")
+	}
+	if m.Match("# Generated code") {
+		t.Error("expected comments to be skipped")
+	}
+}
@@ -0,0 +1,33 @@
+package logdb
+
+// Batch collects writes that are applied to the database as one unit. The
+// zero value is an empty batch that is ready to use.
+type Batch struct {
+	records []Record
+}
+
+// Set adds a key-value pair to the batch.
+func (b *Batch) Set(key string, value []byte) {
+	b.records = append(b.records, Record{Key: key, Value: value})
+}
+
+// Delete adds the removal of a key to the batch.
+func (b *Batch) Delete(key string) {
+	b.records = append(b.records, Record{Key: key, Tombstone: true})
+}
+
+// Merge adds an operand for the key to the batch. The
+// database must have been created with a merge function.
+func (b *Batch) Merge(key string, operand []byte) {
+	b.records = append(b.records, Record{Key: key, Value: operand, Operand: true})
+}
+
+// Len returns the number of writes in the batch.
+func (b *Batch) Len() int {
+	return len(b.records)
+}
+
+// Reset removes every write from the batch, which allows it to be reused.
+func (b *Batch) Reset() {
+	b.records = b.records[:0]
+}
@@ -0,0 +1,99 @@
+package logdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/charmbracelet/log"
+	"github.com/viccon/pulse/fsutil"
+)
+
+// segmentMagic is written at the start of every segment. Segments that were
+// written before the records were framed don't have it. Those hold one JSON
+// encoded record per line, and are migrated when the database is restored.
+const segmentMagic = "PULSEDB1"
+
+// prepareSegment makes sure that the segment at the path is in the framed
+// format. Segments that never got their magic written are initialized, and
+// segments in the legacy format are migrated.
+func prepareSegment(path string, logger *log.Logger) error {
+	data, err := readPrefix(path, len(segmentMagic))
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case string(data) == segmentMagic:
+		return nil
+	case bytes.HasPrefix([]byte(segmentMagic), data):
+		// The segment was created, but nothing was ever written to it.
+		return os.WriteFile(path, []byte(segmentMagic), 0o600)
+	case data[0] == '{':
+		logger.Info("Migrating a segment to the framed format", "segment", filepath.Base(path))
+		return migrateLegacySegment(path, logger)
+	default:
+		return fmt.Errorf("unrecognized format of segment %s", filepath.Base(path))
+	}
+}
+
+// readPrefix reads up to n bytes from the start of the file.
+func readPrefix(path string, n int) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data := make([]byte, n)
+	read, err := io.ReadFull(file, data)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	return data[:read], nil
+}
+
+// migrateLegacySegment rewrites a segment that holds one JSON encoded record
+// per line in the framed format. Lines that can't be decoded are skipped, which
+// is how the legacy format treated them. The segment is replaced atomically.
+func migrateLegacySegment(path string, logger *log.Logger) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	migrated := []byte(segmentMagic)
+	reader := bufio.NewReader(file)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var record Record
+			if unmarshalErr := json.Unmarshal(line, &record); unmarshalErr != nil {
+				logger.Warn("Skipping a legacy record that can't be decoded",
+					"segment", filepath.Base(path),
+					"err", unmarshalErr,
+				)
+			} else {
+				frame, encodeErr := encodeRecord(record)
+				if encodeErr != nil {
+					return encodeErr
+				}
+				migrated = append(migrated, frame...)
+			}
+		}
+		if errors.Is(readErr, io.EOF) {
+			break
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+
+	return fsutil.WriteFileAtomic(path, migrated, 0o600)
+}
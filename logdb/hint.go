@@ -0,0 +1,117 @@
+package logdb
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/viccon/pulse/fsutil"
+)
+
+// hintExtension is the extension of the hint files. A hint file has the same
+// name as the segment it describes, e.g. aaaaaaaaaaaaaaab.hint.
+const hintExtension = ".hint"
+
+// hintEntry describes the location of the most recent record for a key.
+type hintEntry struct {
+	Key       string `json:"key"`
+	Offset    int64  `json:"offset"`
+	Size      int64  `json:"size"`
+	Tombstone bool   `json:"tombstone,omitempty"`
+	// Partial is true if the segment only holds merge operands for the key.
+	Partial  bool    `json:"partial,omitempty"`
+	Operands []int64 `json:"operands,omitempty"`
+}
+
+// hint is written alongside a sealed segment. It holds everything that we need
+// to rebuild the index of the segment, without having to read every value.
+type hint struct {
+	// SegmentSize is the size of the segment when the hint was written. A hint
+	// with a size that doesn't match the segment is stale, and can't be used.
+	SegmentSize int64       `json:"segment_size"`
+	Entries     []hintEntry `json:"entries"`
+}
+
+// hintPath returns the path of the hint file for a segment.
+func hintPath(segmentPath string) string {
+	return strings.TrimSuffix(segmentPath, ".log") + hintExtension
+}
+
+// recordSize reads the header of the record at the given
+// offset, and returns the number of bytes the frame occupies.
+func recordSize(s *Segment, offset int64) (int64, error) {
+	_, length, err := readHeader(s.logFile, offset, s.bytes)
+	if err != nil {
+		return 0, err
+	}
+	return headerSize + int64(length), nil
+}
+
+// writeHint persists the index of a sealed segment to its hint file.
+func (s *Segment) writeHint() error {
+	s.Lock()
+	defer s.Unlock()
+
+	h := hint{SegmentSize: s.bytes, Entries: make([]hintEntry, 0, len(s.hashIndex))}
+	for key, offset := range s.hashIndex {
+		size, err := recordSize(s, offset)
+		if err != nil {
+			return err
+		}
+		_, tombstone := s.tombstones[key]
+		_, partial := s.partial[key]
+		h.Entries = append(h.Entries, hintEntry{
+			Key:       key,
+			Offset:    offset,
+			Size:      size,
+			Tombstone: tombstone,
+			Partial:   partial,
+			Operands:  s.operands[key],
+		})
+	}
+
+	bytes, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+
+	return fsutil.WriteFileAtomic(hintPath(s.logFile.Name()), bytes, 0o600)
+}
+
+// removeHint removes the hint file of a segment, if it has one.
+func removeHint(segmentPath string) error {
+	err := os.Remove(hintPath(segmentPath))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// readHint loads the index of a segment from its hint file. The second return
+// value is false if the hint is missing, can't be decoded, or is stale. The
+// segment has to be scanned in full when that is the case.
+func readHint(segmentPath string, segmentSize int64) (hint, bool) {
+	bytes, err := os.ReadFile(hintPath(segmentPath))
+	if err != nil {
+		return hint{}, false
+	}
+
+	var h hint
+	if err = json.Unmarshal(bytes, &h); err != nil || h.SegmentSize != segmentSize {
+		return hint{}, false
+	}
+
+	for _, entry := range h.Entries {
+		if entry.Offset < 0 || entry.Offset+entry.Size > segmentSize {
+			return hint{}, false
+		}
+		for _, offset := range entry.Operands {
+			if offset < 0 || offset+headerSize > segmentSize {
+				return hint{}, false
+			}
+		}
+	}
+	return h, true
+}
@@ -0,0 +1,110 @@
+package logdb
+
+import (
+	"sort"
+	"strings"
+)
+
+// Iterator walks the keys of the database in sorted order. Only the keys are
+// collected up front. The values are read one at a time as the iterator
+// advances, which means that we don't have to load every value into memory.
+// Keys that are deleted while the iterator is in use are skipped.
+type Iterator struct {
+	get   func(string) ([]byte, bool)
+	keys  []string
+	index int
+	key   string
+	value []byte
+}
+
+// keys returns the sorted keys of the segments that satisfy the predicate.
+// should be called with a lock.
+func keys(segments []*Segment, include func(string) bool) []string {
+	unique := make(map[string]struct{})
+	for _, segment := range segments {
+		for key := range segment.hashIndex {
+			if include(key) {
+				unique[key] = struct{}{}
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(unique))
+	for key := range unique {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// iterator returns an iterator over the keys of the live segments that satisfy the predicate.
+func (db *LogDB) iterator(include func(string) bool) *Iterator {
+	db.RLock()
+	defer db.RUnlock()
+	return &Iterator{get: db.Get, keys: keys(db.segments(), include)}
+}
+
+// Scan returns an iterator over the keys that start with the prefix. Buffer
+// keys start with their date, which makes it possible to scan a single day.
+func (db *LogDB) Scan(prefix string) *Iterator {
+	return db.iterator(func(key string) bool {
+		return strings.HasPrefix(key, prefix)
+	})
+}
+
+// Range returns an iterator over the keys from start (inclusive) to end
+// (exclusive). An empty end means that the range has no upper bound.
+func (db *LogDB) Range(start, end string) *Iterator {
+	return db.iterator(func(key string) bool {
+		return key >= start && (end == "" || key < end)
+	})
+}
+
+// ScanSnapshot returns an iterator over the keys of the pending snapshot that
+// start with the prefix. The iterator is empty if there isn't a pending
+// snapshot, and the values are no longer read once it has been acknowledged.
+func (db *LogDB) ScanSnapshot(prefix string) *Iterator {
+	db.RLock()
+	defer db.RUnlock()
+
+	get := func(key string) ([]byte, bool) {
+		db.RLock()
+		defer db.RUnlock()
+		value, ok, err := resolve(db.snapshot, key, db.merge)
+		if err != nil {
+			db.log.Error("Failed to merge the operands", "key", key, "err", err)
+			return nil, false
+		}
+		return value, ok
+	}
+	return &Iterator{get: get, keys: keys(db.snapshot, func(key string) bool {
+		return strings.HasPrefix(key, prefix)
+	})}
+}
+
+// Next advances the iterator to the next key that has a value.
+// It returns false once there aren't any keys left.
+func (it *Iterator) Next() bool {
+	for it.index < len(it.keys) {
+		key := it.keys[it.index]
+		it.index++
+
+		// The newest record decides the value, and if the key has been deleted.
+		if value, ok := it.get(key); ok {
+			it.key, it.value = key, value
+			return true
+		}
+	}
+	it.key, it.value = "", nil
+	return false
+}
+
+// Key returns the key at the current position of the iterator.
+func (it *Iterator) Key() string {
+	return it.key
+}
+
+// Value returns the value at the current position of the iterator.
+func (it *Iterator) Value() []byte {
+	return it.value
+}
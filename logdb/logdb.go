@@ -2,7 +2,9 @@ package logdb
 
 import (
 	"context"
+	"errors"
 	"os"
+	"path/filepath"
 	"sync"
 	"time"
 
@@ -11,12 +13,6 @@ import (
 	"github.com/viccon/pulse/logger"
 )
 
-// Record represents a key-value pair in our database.
-type Record struct {
-	Key   string `json:"key"`
-	Value []byte `json:"value"`
-}
-
 // LogDB is a simple key-value store that persists data to a log file.
 type LogDB struct {
 	sync.RWMutex
@@ -26,10 +22,13 @@ type LogDB struct {
 	log              *log.Logger
 	head             *Segment
 	tail             *Segment
+	snapshot         []*Segment
+	checkpoints      map[string]struct{}
+	merge            MergeFunc
 }
 
 // NewDB creates a new log database.
-func NewDB(dirPath string, segmentSizeKB int, c clock.Clock) *LogDB {
+func NewDB(dirPath string, segmentSizeKB int, c clock.Clock, opts ...Option) *LogDB {
 	log := logger.New()
 
 	// Create the directory if it doesn't exist.
@@ -42,21 +41,53 @@ func NewDB(dirPath string, segmentSizeKB int, c clock.Clock) *LogDB {
 		log.Fatal("could not get segment paths")
 	}
 
+	// If the snapshot index is corrupt we can't tell which segments were part of the
+	// snapshot. We'll treat every segment as live, which means that they're going to
+	// be part of the next snapshot, without any checkpoints.
+	snapshotIndex, checkpoints, err := readSnapshotIndex(dirPath)
+	if errors.Is(err, errCorruptSnapshotIndex) {
+		log.Error("Ignoring the pending snapshot", "err", err)
+	} else if err != nil {
+		log.Fatal("could not read the snapshot index", "err", err)
+	}
+
 	var logDB LogDB
 	logDB.dirPath = dirPath
 	logDB.segmentSizeBytes = int64(segmentSizeKB) * 1024
 	logDB.log = log
 	logDB.clock = c
+	logDB.checkpoints = make(map[string]struct{}, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		logDB.checkpoints[checkpoint] = struct{}{}
+	}
+	for _, opt := range opts {
+		opt(&logDB)
+	}
 
-	// If the directory is empty, we'll simply create the initial segment and return.
-	if len(segmentPaths) == 0 {
-		segment := newSegment(dirPath, 0)
+	// Segments that are part of a snapshot which was never acknowledged
+	// are restored separately, so that the snapshot can be retried.
+	livePaths := make([]string, 0, len(segmentPaths))
+	for _, p := range segmentPaths {
+		if Index(filepath.Base(p)) > snapshotIndex {
+			livePaths = append(livePaths, p)
+			continue
+		}
+		segment, restoreErr := restoreSegment(p, log, true)
+		if restoreErr != nil {
+			log.Fatal("could not restore the snapshot", "err", restoreErr)
+		}
+		logDB.snapshot = append(logDB.snapshot, segment)
+	}
+
+	// If there aren't any segments, we'll simply create the initial segment and return.
+	if len(livePaths) == 0 {
+		segment := newSegment(dirPath, snapshotIndex+1)
 		logDB.head, logDB.tail = segment, nil
 		return &logDB
 	}
 
 	// Restore the previous segments.
-	segments := restoreSegments(segmentPaths)
+	segments := restoreSegments(livePaths, log)
 
 	var tail *Segment
 	if len(segments) > 1 {
@@ -86,6 +117,11 @@ func (db *LogDB) RunSegmentations(ctx context.Context, segmentationInterval time
 func (db *LogDB) appendSegment() {
 	db.log.Info("Appending a new segment")
 
+	// The current head is sealed, which means that its index won't change.
+	if err := db.head.writeHint(); err != nil {
+		db.log.Error("Failed to write the hint file", "err", err)
+	}
+
 	nextSegmentIndex := db.head.index + 1
 	segment := newSegment(db.dirPath, nextSegmentIndex)
 
@@ -101,77 +137,69 @@ func (db *LogDB) appendSegment() {
 	db.head = segment
 }
 
+// segments returns the segments of the linked list, ordered from newest to
+// oldest. should be called with a lock.
+func (db *LogDB) segments() []*Segment {
+	segments := make([]*Segment, 0)
+	current := db.head
+	for {
+		segments = append(segments, current)
+
+		// Update current and break if we've reached the tail.
+		if current.next == db.head || current.next == nil {
+			break
+		}
+
+		current = current.next
+	}
+	return segments
+}
+
 // compact compacts all of the segments together, removing any duplicate keys.
 func (db *LogDB) compact() {
 	db.Lock()
 	defer db.Unlock()
 
-	currentHead, currentTail := db.head, db.tail
-	current := currentTail
-	if current == nil {
+	if db.tail == nil {
 		db.log.Info("Not enough segments to necessitate a compaction")
 		return
 	}
 
 	db.log.Info("Compacting segments")
+	segments := db.segments()
 	valuesToWrite := make(map[string][]byte)
-
-	for current != nil {
-		current.Lock()
-		for key := range current.hashIndex {
-			var found bool
-			for cursor := currentHead; cursor != current; cursor = cursor.next {
-				_, found = cursor.getNoLock(key)
-				if found {
-					break
-				}
+	for _, segment := range segments[1:] {
+		for key := range segment.hashIndex {
+			// Keys that have a value in the head are already up to date.
+			if _, ok := valuesToWrite[key]; ok || segments[0].hasValue(key) {
+				continue
 			}
 
-			// If this key was unique for all previous segments, we'll write it to the head.
-			if !found {
-				bytes, _ := current.getNoLock(key)
-				valuesToWrite[key] = bytes
+			// The operands are folded into the value that we write to the head.
+			// Keys that have been deleted are dropped. Every segment that is older
+			// than the head is removed by the compaction, so none of them can hold
+			// the key.
+			value, ok, err := resolve(segments, key, db.merge)
+			if err != nil {
+				db.log.Error("Failed to merge the operands", "key", key, "err", err)
+				return
+			}
+			if ok {
+				valuesToWrite[key] = value
 			}
 		}
+	}
 
-		// Adjust pointers to remove the current segment
-		if current == currentHead {
-			// Only one segment left, no need to delete it
-			current.Unlock()
-			break
-		}
-
-		prev := current.prev
-		next := current.next
-
-		if prev != nil {
-			prev.next = next
-		}
-		if next != nil {
-			next.prev = prev
-		}
-
-		if current == db.tail {
-			db.tail = prev
-		}
-		if current == db.head {
-			db.head = next
-		}
-
-		if err := current.delete(); err != nil {
+	for _, segment := range segments[1:] {
+		segment.Lock()
+		err := segment.delete()
+		segment.Unlock()
+		if err != nil {
 			db.log.Error(err)
-			current.Unlock()
 			return
 		}
-
-		nextSegment := prev
-		current.Unlock()
-		current = nextSegment
-	}
-
-	if db.head == db.tail {
-		db.tail = nil
 	}
+	db.head.next, db.head.prev, db.tail = nil, nil, nil
 
 	for key, value := range valuesToWrite {
 		db.mustSet(key, value)
@@ -184,41 +212,22 @@ func (db *LogDB) Get(key string) ([]byte, bool) {
 	db.RLock()
 	defer db.RUnlock()
 
-	current, head := db.head, db.head
-	for {
-		if value, ok := current.get(key); ok {
-			return value, true
-		}
-
-		current = current.next
-
-		if current == nil || current == head {
-			break
-		}
+	value, ok, err := resolve(db.segments(), key, db.merge)
+	if err != nil {
+		db.log.Error("Failed to merge the operands", "key", key, "err", err)
+		return nil, false
 	}
-	return nil, false
+	return value, ok
 }
 
+// GetAllUnique returns the most recent value for every key that hasn't been deleted.
 func (db *LogDB) GetAllUnique() map[string][]byte {
 	db.Lock()
 	defer db.Unlock()
 
-	values := make(map[string][]byte, len(db.head.hashIndex))
-	current := db.head
-	for {
-		for key := range current.hashIndex {
-			if _, ok := values[key]; !ok {
-				value, _ := current.get(key)
-				values[key] = value
-			}
-		}
-
-		// Update current and break if we've reached the tail.
-		if current.next == db.head || current.next == nil {
-			break
-		}
-
-		current = current.next
+	values, err := uniqueValues(db.segments(), db.merge)
+	if err != nil {
+		db.log.Error("Failed to merge the operands", "err", err)
 	}
 	return values
 }
@@ -250,6 +259,75 @@ func (db *LogDB) set(key string, value []byte) error {
 	return nil
 }
 
+// Delete removes a key from the database. It writes a tombstone record,
+// which shadows the values of the key in the older segments until they
+// have been compacted. Keys that are part of a pending snapshot are not
+// affected, as the snapshot has already been handed out.
+func (db *LogDB) Delete(key string) error {
+	db.Lock()
+	defer db.Unlock()
+
+	err := db.head.setTombstone(key)
+	if err != nil {
+		return err
+	}
+	if db.head.size() >= db.segmentSizeBytes {
+		db.appendSegment()
+	}
+	return nil
+}
+
+// WriteBatch applies the writes of the batch atomically. The records are
+// framed together and appended to the head, which means that either all or
+// none of them are restored after a crash. A batch is never split across
+// segments. The head is sealed after the batch if it has grown too large.
+func (db *LogDB) WriteBatch(batch *Batch) error {
+	if batch.Len() == 0 {
+		return nil
+	}
+	for _, r := range batch.records {
+		if r.Operand && db.merge == nil {
+			return ErrNoMergeFunc
+		}
+	}
+
+	db.Lock()
+	defer db.Unlock()
+
+	// The records are copied, so that the batch can be reused by the caller.
+	records := make([]Record, batch.Len())
+	copy(records, batch.records)
+	err := db.head.setBatch(records)
+	if err != nil {
+		return err
+	}
+	if db.head.size() >= db.segmentSizeBytes {
+		db.appendSegment()
+	}
+	return nil
+}
+
+// Merge appends an operand to the key without reading its current value.
+// The operands are folded into the value by the registered merge function
+// when the key is read, and when the segments are compacted.
+func (db *LogDB) Merge(key string, operand []byte) error {
+	if db.merge == nil {
+		return ErrNoMergeFunc
+	}
+
+	db.Lock()
+	defer db.Unlock()
+
+	err := db.head.setOperand(key, operand)
+	if err != nil {
+		return err
+	}
+	if db.head.size() >= db.segmentSizeBytes {
+		db.appendSegment()
+	}
+	return nil
+}
+
 // MustSet writes a key-value pair to the log file and panics on error.
 func (db *LogDB) MustSet(key string, value []byte) {
 	err := db.Set(key, value)
@@ -267,53 +345,3 @@ func (db *LogDB) mustSet(key string, value []byte) {
 		panic(err)
 	}
 }
-
-// Aggregate gathers all the unique key-value pairs in the database,
-// and then removes all the segments and resets the state.
-func (db *LogDB) Aggregate() map[string][]byte {
-	db.log.Info("Aggregating segments")
-	db.Lock()
-	defer db.Unlock()
-
-	values := make(map[string][]byte, len(db.head.hashIndex))
-	current := db.head
-	for {
-		for key := range current.hashIndex {
-			if _, ok := values[key]; !ok {
-				value, _ := current.get(key)
-				values[key] = value
-			}
-		}
-
-		// Check if we've reached the end of the linked list.
-		current.Lock()
-		if current.next == db.head || current.next == nil {
-			err := current.delete()
-			if err != nil {
-				db.log.Error(err)
-			}
-			current.next = nil
-			current.Unlock()
-			break
-		}
-
-		err := current.delete()
-		if err != nil {
-			db.log.Error(err)
-		}
-
-		current.prev.next = nil
-		current.prev = nil
-		current.Unlock()
-		current = current.next
-	}
-
-	db.head = nil
-	db.tail = nil
-
-	segment := newSegment(db.dirPath, 0)
-	db.head, db.tail = segment, nil
-
-	db.log.Info("Aggregation completed")
-	return values
-}
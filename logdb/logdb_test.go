@@ -1,12 +1,15 @@
 package logdb_test
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -66,6 +69,19 @@ func copyDir(srcDir, dstDir string) error {
 	})
 }
 
+// aggregate takes a snapshot of the database and acknowledges it straight away.
+func aggregate(t *testing.T, db *logdb.LogDB) map[string][]byte {
+	t.Helper()
+	values, err := db.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Acknowledge(); err != nil {
+		t.Fatal(err)
+	}
+	return values
+}
+
 func TestConcurrentGetSet(t *testing.T) {
 	t.Parallel()
 
@@ -141,7 +157,7 @@ func TestAggregation(t *testing.T) {
 		t.Errorf("expected 11 values, got %d", len(values))
 	}
 
-	aggregatedValues := db.Aggregate()
+	aggregatedValues := aggregate(t, db)
 	if len(aggregatedValues) != 11 {
 		t.Errorf("expected 11 values, got %d", len(aggregatedValues))
 	}
@@ -206,7 +222,7 @@ func TestAggregationAfterCompaction(t *testing.T) {
 		t.Errorf("expected 11 values, got %d", len(values))
 	}
 
-	aggregatedValues := db.Aggregate()
+	aggregatedValues := aggregate(t, db)
 	if len(aggregatedValues) != 11 {
 		t.Errorf("expected 11 values, got %d", len(aggregatedValues))
 	}
@@ -252,7 +268,7 @@ func TestCompactionWritesAggregation(t *testing.T) {
 		t.Errorf("expected 21 values, got %d", len(values))
 	}
 
-	aggregatedValues := db.Aggregate()
+	aggregatedValues := aggregate(t, db)
 	if len(aggregatedValues) != 21 {
 		t.Errorf("expected 21 values, got %d", len(aggregatedValues))
 	}
@@ -312,7 +328,7 @@ func TestAppendingCompactingWritesAggregation(t *testing.T) {
 		t.Errorf("expected 1011 values, got %d", len(values))
 	}
 
-	aggregatedValues := db.Aggregate()
+	aggregatedValues := aggregate(t, db)
 	if len(aggregatedValues) != 1011 {
 		t.Errorf("expected 1011 values, got %d", len(aggregatedValues))
 	}
@@ -359,3 +375,694 @@ func TestWritesCompacting(t *testing.T) {
 		t.Errorf("expected 1000 values, got %d", len(values))
 	}
 }
+
+func TestRestoreTruncatesTornWrite(t *testing.T) {
+	t.Parallel()
+
+	path := t.TempDir()
+	err := copyDir("testdata/segments/two", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a crash that happened halfway through writing a record.
+	headPath := filepath.Join(path, logdb.Filename(1))
+	info, err := os.Stat(headPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.OpenFile(headPath, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = file.Write([]byte{0, 0, 0, 1, 0, 0, 0, 64, '{', '"'}); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	db := logdb.NewDB(path, 10, clock.NewMock(time.Now()))
+	values := db.GetAllUnique()
+	if len(values) != 11 {
+		t.Errorf("expected 11 values, got %d", len(values))
+	}
+
+	truncatedInfo, err := os.Stat(headPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if truncatedInfo.Size() != info.Size() {
+		t.Errorf("expected the torn write to be truncated to %d bytes, got %d", info.Size(), truncatedInfo.Size())
+	}
+
+	db.MustSet("key", []byte("value"))
+	if value, ok := db.Get("key"); !ok || string(value) != "value" {
+		t.Errorf("expected to read the value that was written after the truncation, got %q", value)
+	}
+}
+
+func TestRestoreTruncatesZeroFilledTail(t *testing.T) {
+	t.Parallel()
+
+	path := t.TempDir()
+	err := copyDir("testdata/segments/two", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a loss of power that left the end of the head filled with zeros.
+	headPath := filepath.Join(path, logdb.Filename(1))
+	info, err := os.Stat(headPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.OpenFile(headPath, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = file.Write(make([]byte, 4096)); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	db := logdb.NewDB(path, 10, clock.NewMock(time.Now()))
+	if values := db.GetAllUnique(); len(values) != 11 {
+		t.Errorf("expected 11 values, got %d", len(values))
+	}
+
+	truncatedInfo, err := os.Stat(headPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if truncatedInfo.Size() != info.Size() {
+		t.Errorf("expected the zeros to be truncated to %d bytes, got %d", info.Size(), truncatedInfo.Size())
+	}
+
+	// The records that are written after the truncation should be restored.
+	db.MustSet("key", []byte("value"))
+	restoredDB := logdb.NewDB(path, 10, clock.NewMock(time.Now()))
+	if value, ok := restoredDB.Get("key"); !ok || string(value) != "value" {
+		t.Errorf("expected the value that was written after the truncation, got %q", value)
+	}
+}
+
+func TestRestoreMigratesLegacySegments(t *testing.T) {
+	t.Parallel()
+
+	// The segments hold one JSON encoded record per line, which is how
+	// they were written before the records were framed.
+	path := t.TempDir()
+	err := copyDir("testdata/segments/legacy", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := logdb.NewDB(path, 10, clock.NewMock(time.Now()))
+	if values := db.GetAllUnique(); len(values) != 11 {
+		t.Errorf("expected 11 values, got %d", len(values))
+	}
+	db.MustSet("key", []byte("value"))
+
+	// The migrated segments should be restored as they are.
+	restoredDB := logdb.NewDB(path, 10, clock.NewMock(time.Now()))
+	if values := restoredDB.GetAllUnique(); len(values) != 12 {
+		t.Errorf("expected 12 values, got %d", len(values))
+	}
+	if value, ok := restoredDB.Get("key"); !ok || string(value) != "value" {
+		t.Errorf("expected the value that was written after the migration, got %q", value)
+	}
+}
+
+func TestRestoreSkipsCorruptRecords(t *testing.T) {
+	t.Parallel()
+
+	path := t.TempDir()
+	db := logdb.NewDB(path, 10, clock.NewMock(time.Now()))
+	db.MustSet("key1", []byte("value1"))
+	db.MustSet("key2", []byte("value2"))
+	db.MustSet("key3", []byte("value3"))
+
+	// Flip a bit in the payload of the second record.
+	segmentPath := filepath.Join(path, logdb.Filename(0))
+	bytes, err := os.ReadFile(segmentPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bytes[len(bytes)/2] ^= 0x01
+	if err = os.WriteFile(segmentPath, bytes, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	restoredDB := logdb.NewDB(path, 10, clock.NewMock(time.Now()))
+	if _, ok := restoredDB.Get("key2"); ok {
+		t.Error("expected the corrupt record to be skipped")
+	}
+	if value, ok := restoredDB.Get("key1"); !ok || string(value) != "value1" {
+		t.Errorf("expected value1, got %q", value)
+	}
+	if value, ok := restoredDB.Get("key3"); !ok || string(value) != "value3" {
+		t.Errorf("expected value3, got %q", value)
+	}
+}
+
+func TestRestoreSkipsCorruptHeaders(t *testing.T) {
+	t.Parallel()
+
+	path := t.TempDir()
+	db := logdb.NewDB(path, 10, clock.NewMock(time.Now()))
+	db.MustSet("key1", []byte("value1"))
+	db.MustSet("key2", []byte("value2"))
+	db.MustSet("key3", []byte("value3"))
+
+	// Flip a bit in the length of the first record. The header is 12 bytes,
+	// and the length follows the checksum of the payload.
+	segmentPath := filepath.Join(path, logdb.Filename(0))
+	data, err := os.ReadFile(segmentPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offset := bytes.Index(data, []byte(`{"key":"key1"`)) - 12
+	data[offset+4] ^= 0x40
+	if err = os.WriteFile(segmentPath, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	restoredDB := logdb.NewDB(path, 10, clock.NewMock(time.Now()))
+	if _, ok := restoredDB.Get("key1"); ok {
+		t.Error("expected the record with the corrupt header to be skipped")
+	}
+	for _, key := range []string{"key2", "key3"} {
+		if _, ok := restoredDB.Get(key); !ok {
+			t.Errorf("expected %s to be restored", key)
+		}
+	}
+
+	info, err := os.Stat(segmentPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != int64(len(data)) {
+		t.Errorf("expected the segment to be left at %d bytes, got %d", len(data), info.Size())
+	}
+}
+
+func TestUnacknowledgedSnapshotIsRetried(t *testing.T) {
+	t.Parallel()
+
+	path := t.TempDir()
+	err := copyDir("testdata/segments/two", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := logdb.NewDB(path, 10, clock.NewMock(time.Now()))
+	values, err := db.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 11 {
+		t.Errorf("expected 11 values, got %d", len(values))
+	}
+
+	// Writes that happen after the snapshot shouldn't see the sealed values.
+	db.MustSet("key", []byte("value"))
+	values = db.GetAllUnique()
+	if len(values) != 1 {
+		t.Errorf("expected 1 value, got %d", len(values))
+	}
+
+	// Without an acknowledgement, the next snapshot should return
+	// the same values. That should also hold after a restart.
+	restoredDB := logdb.NewDB(path, 10, clock.NewMock(time.Now()))
+	if values = restoredDB.GetAllUnique(); len(values) != 1 {
+		t.Errorf("expected 1 value, got %d", len(values))
+	}
+	if values = aggregate(t, restoredDB); len(values) != 11 {
+		t.Errorf("expected 11 values, got %d", len(values))
+	}
+
+	// Now that the first snapshot has been acknowledged, the next one should contain the new value.
+	if values = aggregate(t, restoredDB); len(values) != 1 {
+		t.Errorf("expected 1 value, got %d", len(values))
+	}
+	if values = restoredDB.GetAllUnique(); len(values) != 0 {
+		t.Errorf("expected 0 values, got %d", len(values))
+	}
+}
+
+func TestCorruptSnapshotIndexIsIgnored(t *testing.T) {
+	t.Parallel()
+
+	path := t.TempDir()
+	err := copyDir("testdata/segments/two", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := logdb.NewDB(path, 10, clock.NewMock(time.Now()))
+	if _, err = db.Snapshot(); err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Checkpoint("2024-06-16"); err != nil {
+		t.Fatal(err)
+	}
+	db.MustSet("key", []byte("value"))
+
+	// Simulate a loss of power that left the snapshot index empty.
+	if err = os.WriteFile(filepath.Join(path, "snapshot"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every segment should be live, without any checkpoints.
+	restoredDB := logdb.NewDB(path, 10, clock.NewMock(time.Now()))
+	if restoredDB.Checkpointed("2024-06-16") {
+		t.Error("expected the checkpoints of the corrupt snapshot to be dropped")
+	}
+	if values := restoredDB.GetAllUnique(); len(values) != 12 {
+		t.Errorf("expected 12 values, got %d", len(values))
+	}
+	if values := aggregate(t, restoredDB); len(values) != 12 {
+		t.Errorf("expected 12 values, got %d", len(values))
+	}
+}
+
+func TestCheckpointsSurviveRestart(t *testing.T) {
+	t.Parallel()
+
+	path := t.TempDir()
+	db := logdb.NewDB(path, 10, clock.NewMock(time.Now()))
+	if err := db.Checkpoint("2024-06-16"); err == nil {
+		t.Error("expected a checkpoint without a pending snapshot to fail")
+	}
+
+	db.MustSet("key", []byte("value"))
+	if _, err := db.Snapshot(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Checkpoint("2024-06-16"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The checkpoints should be restored along with the pending snapshot.
+	restoredDB := logdb.NewDB(path, 10, clock.NewMock(time.Now()))
+	if !restoredDB.Checkpointed("2024-06-16") || restoredDB.Checkpointed("2024-06-17") {
+		t.Error("expected only the checkpoint that was recorded to be restored")
+	}
+	if values := aggregate(t, restoredDB); len(values) != 1 {
+		t.Errorf("expected 1 value, got %d", len(values))
+	}
+
+	// Acknowledging the snapshot should remove the checkpoints.
+	if restoredDB.Checkpointed("2024-06-16") {
+		t.Error("expected the checkpoints to be removed with the snapshot")
+	}
+	restoredDB = logdb.NewDB(path, 10, clock.NewMock(time.Now()))
+	if restoredDB.Checkpointed("2024-06-16") {
+		t.Error("expected the checkpoints to be removed from disk with the snapshot")
+	}
+}
+
+func TestDelete(t *testing.T) {
+	t.Parallel()
+
+	path := t.TempDir()
+	mockClock := clock.NewMock(time.Now())
+	db := logdb.NewDB(path, 1, mockClock)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go db.RunSegmentations(ctx, time.Minute*5)
+	time.Sleep(time.Millisecond * 50)
+
+	value := []byte(strings.Repeat("x", 100))
+	for i := range 10 {
+		db.MustSet(fmt.Sprintf("key-%d", i), value)
+	}
+	if err := db.Delete("key-0"); err != nil {
+		t.Fatal(err)
+	}
+	// Fill up the segment to make sure that the tombstone isn't in the head.
+	for i := 10; i < 20; i++ {
+		db.MustSet(fmt.Sprintf("key-%d", i), value)
+	}
+
+	if _, ok := db.Get("key-0"); ok {
+		t.Error("expected the deleted key to be missing")
+	}
+	if values := db.GetAllUnique(); len(values) != 19 {
+		t.Errorf("expected 19 values, got %d", len(values))
+	}
+
+	// The tombstone should be honored when the segments are restored.
+	restored := logdb.NewDB(path, 1, mockClock)
+	if _, ok := restored.Get("key-0"); ok {
+		t.Error("expected the deleted key to be missing after a restore")
+	}
+
+	// A compaction should drop the tombstone along with the value.
+	mockClock.Add(time.Minute * 5)
+	time.Sleep(time.Millisecond * 250)
+	if values := db.GetAllUnique(); len(values) != 19 {
+		t.Errorf("expected 19 values after the compaction, got %d", len(values))
+	}
+	segments, err := filepath.Glob(filepath.Join(path, "*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, segment := range segments {
+		content, readErr := os.ReadFile(segment)
+		if readErr != nil {
+			t.Fatal(readErr)
+		}
+		if strings.Contains(string(content), `"key-0"`) {
+			t.Errorf("expected the compaction to drop the deleted key from %s", filepath.Base(segment))
+		}
+	}
+
+	// Deleted keys shouldn't be a part of the snapshot.
+	if err = db.Delete("key-1"); err != nil {
+		t.Fatal(err)
+	}
+	if values := aggregate(t, db); len(values) != 18 {
+		t.Errorf("expected 18 values in the snapshot, got %d", len(values))
+	}
+}
+
+func TestRestoreFromHintFiles(t *testing.T) {
+	t.Parallel()
+
+	path := t.TempDir()
+	db := logdb.NewDB(path, 1, clock.New())
+	value := []byte(strings.Repeat("x", 100))
+	for i := range 20 {
+		db.MustSet(fmt.Sprintf("key-%d", i), value)
+	}
+	if err := db.Delete("key-0"); err != nil {
+		t.Fatal(err)
+	}
+	expected := db.GetAllUnique()
+
+	hints, err := filepath.Glob(filepath.Join(path, "*.hint"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hints) == 0 {
+		t.Fatal("expected the sealed segments to have hint files")
+	}
+
+	restored := logdb.NewDB(path, 1, clock.New())
+	if values := restored.GetAllUnique(); len(values) != len(expected) {
+		t.Errorf("expected %d values after restoring from the hint files, got %d", len(expected), len(values))
+	}
+	if _, ok := restored.Get("key-0"); ok {
+		t.Error("expected the tombstone to be restored from the hint files")
+	}
+
+	// Hint files that can't be decoded, or are stale, should be ignored.
+	if err = os.WriteFile(hints[0], []byte("garbage"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	restored = logdb.NewDB(path, 1, clock.New())
+	for key, v := range expected {
+		if got, ok := restored.Get(key); !ok || string(got) != string(v) {
+			t.Errorf("expected %s to be restored by scanning the segment", key)
+		}
+	}
+
+	// The segment is scanned in full, and the hint file is rewritten.
+	content, err := os.ReadFile(hints[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) == "garbage" {
+		t.Error("expected the hint file to be rewritten")
+	}
+}
+
+func TestScanAndRange(t *testing.T) {
+	t.Parallel()
+
+	db := logdb.NewDB(t.TempDir(), 1, clock.New())
+	keys := []string{
+		"2024-06-17_pulse_main.go",
+		"2024-06-16_pulse_server.go",
+		"2024-06-16_dotfiles_install.sh",
+		"2024-06-15_pulse_main.go",
+		"2024-06-16_pulse_deleted.go",
+	}
+	for _, key := range keys {
+		db.MustSet(key, []byte(strings.Repeat("x", 100)))
+	}
+	// Overwrite a value, and delete a key, to make sure that the newest record is used.
+	db.MustSet("2024-06-16_pulse_server.go", []byte("newest"))
+	if err := db.Delete("2024-06-16_pulse_deleted.go"); err != nil {
+		t.Fatal(err)
+	}
+
+	collect := func(it *logdb.Iterator) []string {
+		result := make([]string, 0)
+		for it.Next() {
+			result = append(result, it.Key())
+			if it.Key() == "2024-06-16_pulse_server.go" && string(it.Value()) != "newest" {
+				t.Errorf("expected the newest value, got %s", it.Value())
+			}
+		}
+		return result
+	}
+
+	scanned := collect(db.Scan("2024-06-16_"))
+	expected := []string{"2024-06-16_dotfiles_install.sh", "2024-06-16_pulse_server.go"}
+	if strings.Join(scanned, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %v, got %v", expected, scanned)
+	}
+
+	ranged := collect(db.Range("2024-06-15", "2024-06-17"))
+	expected = []string{"2024-06-15_pulse_main.go", "2024-06-16_dotfiles_install.sh", "2024-06-16_pulse_server.go"}
+	if strings.Join(ranged, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %v, got %v", expected, ranged)
+	}
+
+	if unbounded := collect(db.Range("2024-06-16_q", "")); len(unbounded) != 1 {
+		t.Errorf("expected a range without an end to include the last key, got %v", unbounded)
+	}
+
+	// The keys that are part of a pending snapshot are scanned separately.
+	if _, err := db.Snapshot(); err != nil {
+		t.Fatal(err)
+	}
+	if scanned = collect(db.Scan("2024-06-16_")); len(scanned) != 0 {
+		t.Errorf("expected the snapshot to be left out of the scan, got %v", scanned)
+	}
+	expected = []string{"2024-06-16_dotfiles_install.sh", "2024-06-16_pulse_server.go"}
+	if scanned = collect(db.ScanSnapshot("2024-06-16_")); strings.Join(scanned, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %v, got %v", expected, scanned)
+	}
+	if err := db.Acknowledge(); err != nil {
+		t.Fatal(err)
+	}
+	if scanned = collect(db.ScanSnapshot("2024-06-16_")); len(scanned) != 0 {
+		t.Errorf("expected the acknowledged snapshot to be empty, got %v", scanned)
+	}
+}
+
+func TestWriteBatch(t *testing.T) {
+	t.Parallel()
+
+	path := t.TempDir()
+	db := logdb.NewDB(path, 1, clock.NewMock(time.Now()))
+	db.MustSet("a", []byte("1"))
+	db.MustSet("b", []byte("2"))
+
+	// The batch is larger than a segment, and has to be kept together.
+	var batch logdb.Batch
+	for i := 0; i < 10; i++ {
+		batch.Set(fmt.Sprintf("batch_%d", i), []byte(strings.Repeat("x", 200)))
+	}
+	batch.Delete("b")
+	if err := db.WriteBatch(&batch); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if _, ok := db.Get(fmt.Sprintf("batch_%d", i)); !ok {
+			t.Errorf("expected batch_%d to be written", i)
+		}
+	}
+	if _, ok := db.Get("b"); ok {
+		t.Error("expected b to be deleted by the batch")
+	}
+
+	segments, err := filepath.Glob(filepath.Join(path, "*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 2 {
+		t.Fatalf("expected the segment to be sealed after the batch, got %d segments", len(segments))
+	}
+
+	// Simulate a crash that happened halfway through writing another batch.
+	batch.Reset()
+	batch.Set("a", []byte("3"))
+	batch.Set("c", []byte(strings.Repeat("y", 100)))
+	if err = db.WriteBatch(&batch); err != nil {
+		t.Fatal(err)
+	}
+	headPath := segments[len(segments)-1]
+	info, err := os.Stat(headPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Truncate(headPath, info.Size()-10); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := logdb.NewDB(path, 1, clock.NewMock(time.Now()))
+	if value, ok := restored.Get("a"); !ok || string(value) != "1" {
+		t.Errorf("expected a to keep the value from before the torn batch, got %q", value)
+	}
+	if _, ok := restored.Get("c"); ok {
+		t.Error("expected none of the writes in the torn batch to be restored")
+	}
+	if values := restored.GetAllUnique(); len(values) != 11 {
+		t.Errorf("expected 11 values, got %d", len(values))
+	}
+}
+
+// sum is a merge function which adds integers together.
+func sum(value []byte, operands [][]byte) ([]byte, error) {
+	var total int
+	for _, operand := range append([][]byte{value}, operands...) {
+		if operand == nil {
+			continue
+		}
+		n, err := strconv.Atoi(string(operand))
+		if err != nil {
+			return nil, err
+		}
+		total += n
+	}
+	return []byte(strconv.Itoa(total)), nil
+}
+
+func TestMerge(t *testing.T) {
+	t.Parallel()
+
+	if err := logdb.NewDB(t.TempDir(), 1, clock.New()).Merge("a", []byte("1")); err == nil {
+		t.Error("expected a merge without a merge function to fail")
+	}
+
+	path := t.TempDir()
+	mockClock := clock.NewMock(time.Now())
+	db := logdb.NewDB(path, 1, mockClock, logdb.WithMergeFunc(sum))
+	db.MustSet("a", []byte("10"))
+	db.MustSet("c", []byte("10"))
+
+	// Write enough operands for them to be spread across several segments.
+	for i := 0; i < 100; i++ {
+		if err := db.Merge("a", []byte("1")); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Merge("b", []byte("2")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Delete("c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Merge("c", []byte("5")); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{"a": "110", "b": "200", "c": "5"}
+	for key, value := range expected {
+		if got, ok := db.Get(key); !ok || string(got) != value {
+			t.Errorf("expected %s to be %s, got %q", key, value, got)
+		}
+	}
+
+	// The operands should be folded the same way after a restore.
+	restored := logdb.NewDB(path, 1, mockClock, logdb.WithMergeFunc(sum))
+	for key, value := range restored.GetAllUnique() {
+		if string(value) != expected[key] {
+			t.Errorf("expected the restored %s to be %s, got %q", key, expected[key], value)
+		}
+	}
+
+	// Compaction should fold the operands into the head.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go restored.RunSegmentations(ctx, time.Minute)
+	time.Sleep(time.Millisecond * 50)
+	mockClock.Add(time.Minute)
+	time.Sleep(time.Millisecond * 250)
+
+	segments, err := filepath.Glob(filepath.Join(path, "*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 1 {
+		t.Errorf("expected the segments to be compacted into one, got %d", len(segments))
+	}
+	if err = restored.Merge("a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	expected["a"] = "111"
+
+	values, err := restored.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != len(expected) {
+		t.Errorf("expected %d values, got %d", len(expected), len(values))
+	}
+	for key, value := range values {
+		if string(value) != expected[key] {
+			t.Errorf("expected the snapshot of %s to be %s, got %q", key, expected[key], value)
+		}
+	}
+}
+
+func TestWriteBatchWithMerges(t *testing.T) {
+	t.Parallel()
+
+	var batch logdb.Batch
+	batch.Merge("a", []byte("1"))
+	if err := logdb.NewDB(t.TempDir(), 1, clock.New()).WriteBatch(&batch); err == nil {
+		t.Error("expected a batch with merges to fail without a merge function")
+	}
+	batch.Reset()
+
+	path := t.TempDir()
+	db := logdb.NewDB(path, 10, clock.New(), logdb.WithMergeFunc(sum))
+	db.MustSet("a", []byte("10"))
+
+	// A batch can hold several operands for the same key, and
+	// values that the operands which follow them build upon.
+	batch.Merge("a", []byte("1"))
+	batch.Merge("a", []byte("2"))
+	batch.Merge("b", []byte("3"))
+	batch.Set("c", []byte("4"))
+	batch.Merge("c", []byte("5"))
+	batch.Merge("c", []byte("6"))
+	batch.Merge("d", []byte("7"))
+	batch.Set("d", []byte("8"))
+	if err := db.WriteBatch(&batch); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Merge("a", []byte("4")); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{"a": "17", "b": "3", "c": "15", "d": "8"}
+	for key, value := range expected {
+		if got, ok := db.Get(key); !ok || string(got) != value {
+			t.Errorf("expected %s to be %s, got %q", key, value, got)
+		}
+	}
+
+	// The operands should be folded the same way after a restore.
+	restored := logdb.NewDB(path, 10, clock.New(), logdb.WithMergeFunc(sum))
+	for key, value := range expected {
+		if got, ok := restored.Get(key); !ok || string(got) != value {
+			t.Errorf("expected the restored %s to be %s, got %q", key, value, got)
+		}
+	}
+}
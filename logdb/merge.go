@@ -0,0 +1,51 @@
+package logdb
+
+import (
+	"errors"
+)
+
+var ErrNoMergeFunc = errors.New("the database doesn't have a merge function")
+
+// MergeFunc folds the operands of a key into its value. The value is nil if
+// the key doesn't have one, and the operands are ordered from oldest to newest.
+type MergeFunc func(value []byte, operands [][]byte) ([]byte, error)
+
+// resolve returns the value of a key, with every operand that has been written
+// since the value folded into it. The segments are expected to be ordered from
+// newest to oldest.
+func resolve(segments []*Segment, key string, merge MergeFunc) ([]byte, bool, error) {
+	var operands [][]byte
+	for _, segment := range segments {
+		e, ok := segment.entry(key)
+		if !ok {
+			continue
+		}
+
+		// The operands of older segments were written before the ones we've already seen.
+		operands = append(e.operands, operands...)
+		if e.partial {
+			continue
+		}
+		if len(operands) == 0 {
+			return e.value, !e.deleted, nil
+		}
+		return fold(merge, e.value, operands)
+	}
+
+	if len(operands) == 0 {
+		return nil, false, nil
+	}
+	return fold(merge, nil, operands)
+}
+
+// fold applies the merge function to the value and operands.
+func fold(merge MergeFunc, value []byte, operands [][]byte) ([]byte, bool, error) {
+	if merge == nil {
+		return nil, false, ErrNoMergeFunc
+	}
+	merged, err := merge(value, operands)
+	if err != nil {
+		return nil, false, err
+	}
+	return merged, true, nil
+}
@@ -0,0 +1,10 @@
+package logdb
+
+type Option func(*LogDB)
+
+// WithMergeFunc registers the function that folds the operands of Merge.
+func WithMergeFunc(merge MergeFunc) Option {
+	return func(db *LogDB) {
+		db.merge = merge
+	}
+}
@@ -0,0 +1,156 @@
+package logdb
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// headerSize is the number of bytes that precedes every record in a segment.
+// The header holds a CRC-32 checksum of the payload, the length of the payload,
+// and a CRC-32 checksum of the first two fields. The length can't be trusted
+// unless the checksum of the header matches.
+const headerSize = 12
+
+var (
+	ErrChecksumMismatch = errors.New("the checksum of the record does not match its payload")
+	ErrCorruptHeader    = errors.New("the checksum of the record header does not match")
+	ErrTornRecord       = errors.New("the record was only partially written")
+)
+
+// Record represents a key-value pair in our database.
+type Record struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+	// Tombstone is true for records that mark the key as deleted.
+	Tombstone bool `json:"tombstone,omitempty"`
+	// Operand is true for records that are merged into the value of the key.
+	Operand bool `json:"operand,omitempty"`
+	// Batch holds the records of a write batch. The records are framed together,
+	// which means that a torn or corrupt batch is discarded as a whole.
+	Batch []Record `json:"batch,omitempty"`
+}
+
+// entries returns the key-value records that the record holds.
+func (r Record) entries() []Record {
+	if r.Batch != nil {
+		return r.Batch
+	}
+	return []Record{r}
+}
+
+// lookup returns the most recent value, or tombstone, for the key.
+func (r Record) lookup(key string) (Record, bool) {
+	entries := r.entries()
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Key == key && !entries[i].Operand {
+			return entries[i], true
+		}
+	}
+	return Record{}, false
+}
+
+// operands returns the operands for the key that were
+// written after its most recent value, in the order of writing.
+func (r Record) operands(key string) [][]byte {
+	var operands [][]byte
+	for _, e := range r.entries() {
+		if e.Key != key {
+			continue
+		}
+		if !e.Operand {
+			operands = operands[:0]
+			continue
+		}
+		operands = append(operands, e.Value)
+	}
+	return operands
+}
+
+// CorruptRecordError is used to report a record that
+// could not be read from the segment it was written to.
+type CorruptRecordError struct {
+	Segment string
+	Offset  int64
+	Err     error
+}
+
+func (e *CorruptRecordError) Error() string {
+	return fmt.Sprintf("corrupt record in segment %s at offset %d: %v", e.Segment, e.Offset, e.Err)
+}
+
+func (e *CorruptRecordError) Unwrap() error {
+	return e.Err
+}
+
+// encodeRecord frames the record with a header that
+// contains the checksum and length of the payload.
+func encodeRecord(r Record) ([]byte, error) {
+	payload, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+
+	frame := make([]byte, headerSize, headerSize+len(payload))
+	binary.BigEndian.PutUint32(frame[0:4], crc32.ChecksumIEEE(payload))
+	binary.BigEndian.PutUint32(frame[4:8], uint32(len(payload)))
+	binary.BigEndian.PutUint32(frame[8:12], crc32.ChecksumIEEE(frame[0:8]))
+	return append(frame, payload...), nil
+}
+
+// readHeader reads the header of the record at the given offset,
+// and returns the checksum of the payload along with its length.
+func readHeader(r io.ReaderAt, offset, end int64) (uint32, uint32, error) {
+	if offset+headerSize > end {
+		return 0, 0, ErrTornRecord
+	}
+
+	header := make([]byte, headerSize)
+	if _, err := r.ReadAt(header, offset); err != nil {
+		if errors.Is(err, io.EOF) {
+			return 0, 0, ErrTornRecord
+		}
+		return 0, 0, err
+	}
+
+	if crc32.ChecksumIEEE(header[0:8]) != binary.BigEndian.Uint32(header[8:12]) {
+		return 0, 0, ErrCorruptHeader
+	}
+	return binary.BigEndian.Uint32(header[0:4]), binary.BigEndian.Uint32(header[4:8]), nil
+}
+
+// readRecord reads the record that starts at the given offset. The end is the
+// size of the segment. A record with an intact header that extends past the end
+// has been torn. It returns the record along with the total number of bytes
+// that the frame occupies.
+func readRecord(r io.ReaderAt, offset, end int64) (Record, int64, error) {
+	checksum, length, err := readHeader(r, offset, end)
+	if err != nil {
+		return Record{}, 0, err
+	}
+	if offset+headerSize+int64(length) > end {
+		return Record{}, 0, ErrTornRecord
+	}
+
+	payload := make([]byte, length)
+	if _, err := r.ReadAt(payload, offset+headerSize); err != nil {
+		if errors.Is(err, io.EOF) {
+			return Record{}, 0, ErrTornRecord
+		}
+		return Record{}, 0, err
+	}
+
+	size := int64(headerSize) + int64(length)
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return Record{}, size, ErrChecksumMismatch
+	}
+
+	var record Record
+	if err := json.Unmarshal(payload, &record); err != nil {
+		return Record{}, size, err
+	}
+	return record, size, nil
+}
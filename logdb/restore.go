@@ -5,6 +5,8 @@ import (
 	"path"
 	"path/filepath"
 	"sort"
+
+	"github.com/charmbracelet/log"
 )
 
 // getSegmentPaths returns a sorted list of every segments log file in the directory.
@@ -16,7 +18,7 @@ func getSegmentPaths(dirPath string) ([]string, error) {
 
 	filePaths := make([]string, 0, len(entries))
 	for _, entry := range entries {
-		if entry.IsDir() {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".log" {
 			continue
 		}
 		filePaths = append(filePaths, path.Join(dirPath, entry.Name()))
@@ -28,13 +30,17 @@ func getSegmentPaths(dirPath string) ([]string, error) {
 	return filePaths, nil
 }
 
-// restoreSegment reads a log file and restores it to a segment.
-func restoreSegment(path string) (*Segment, error) {
-	var bytes int64
-	hashIndex := make(HashIndex)
-	for record := range scan(path) {
-		hashIndex[record.Key] = record.Offset
-		bytes += int64(len(record.Key)) + int64(len(record.Value))
+// restoreFromHint restores a segment using the index in its hint file.
+// It returns nil if the segment doesn't have a hint that can be used.
+func restoreFromHint(path string) (*Segment, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	h, ok := readHint(path, info.Size())
+	if !ok {
+		return nil, nil
 	}
 
 	file, err := os.OpenFile(path, os.O_RDWR, os.ModePerm)
@@ -42,12 +48,84 @@ func restoreSegment(path string) (*Segment, error) {
 		return nil, err
 	}
 
+	segment := emptySegment(Index(filepath.Base(path)), file)
+	segment.bytes = info.Size()
+	for _, entry := range h.Entries {
+		segment.hashIndex[entry.Key] = entry.Offset
+		if entry.Tombstone {
+			segment.tombstones[entry.Key] = struct{}{}
+		}
+		if entry.Partial {
+			segment.partial[entry.Key] = struct{}{}
+		}
+		if len(entry.Operands) > 0 {
+			segment.operands[entry.Key] = entry.Operands
+		}
+	}
+	return segment, nil
+}
+
+// restoreSegment reads a log file and restores it to a segment. Sealed
+// segments are restored from their hint file when it's up to date. Otherwise,
+// the log file is scanned in full. Records that are corrupt are reported and
+// left out of the index, and if the file ends with a torn write we'll truncate
+// it so that new records can be appended. Sealed segments that were scanned
+// get a new hint file, which speeds up the next restore. Segments in the legacy
+// format are migrated before they're restored.
+func restoreSegment(path string, logger *log.Logger, sealed bool) (*Segment, error) {
+	if err := prepareSegment(path, logger); err != nil {
+		return nil, err
+	}
+
+	if sealed {
+		segment, err := restoreFromHint(path)
+		if err != nil {
+			return nil, err
+		}
+		if segment != nil {
+			return segment, nil
+		}
+	}
+
 	filename := filepath.Base(path)
-	segment := &Segment{
-		index:     Index(filename),
-		bytes:     bytes,
-		hashIndex: hashIndex,
-		logFile:   file,
+	segment := emptySegment(Index(filename), nil)
+	result, err := scan(path, func(record RecordWithOffset) {
+		segment.indexRecord(record.Record, record.Offset)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, corruptErr := range result.corrupted {
+		logger.Error("Skipping a corrupt record",
+			"segment", corruptErr.Segment,
+			"offset", corruptErr.Offset,
+			"err", corruptErr.Err,
+		)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.torn {
+		logger.Warn("Truncating a torn write at the end of the segment",
+			"segment", filename,
+			"offset", result.size,
+		)
+		if truncateErr := file.Truncate(result.size); truncateErr != nil {
+			file.Close()
+			return nil, truncateErr
+		}
+	}
+
+	segment.bytes, segment.logFile = result.size, file
+
+	if sealed {
+		if hintErr := segment.writeHint(); hintErr != nil {
+			logger.Error("Failed to write the hint file", "segment", filename, "err", hintErr)
+		}
 	}
 
 	return segment, nil
@@ -74,10 +152,11 @@ func connectSegments(segments []*Segment) {
 }
 
 // restoreSegments reads all log files in the directory and restores them to segments.
-func restoreSegments(segmentPaths []string) []*Segment {
+func restoreSegments(segmentPaths []string, logger *log.Logger) []*Segment {
 	segments := make([]*Segment, 0, len(segmentPaths))
-	for _, p := range segmentPaths {
-		segment, err := restoreSegment(p)
+	for i, p := range segmentPaths {
+		// Every segment but the newest one has been sealed.
+		segment, err := restoreSegment(p, logger, i > 0)
 		if err != nil {
 			panic(err)
 		}
@@ -1,9 +1,10 @@
 package logdb
 
 import (
-	"bufio"
-	"encoding/json"
+	"errors"
+	"io"
 	"os"
+	"path/filepath"
 )
 
 // RecordWithOffset holds a record and its offset in the log file.
@@ -12,31 +13,101 @@ type RecordWithOffset struct {
 	Offset int64
 }
 
-// scan reads a log file and sends each record to a channel along with its offset.
-func scan(filepath string) <-chan RecordWithOffset {
-	ch := make(chan RecordWithOffset)
+// scanResult describes the outcome of scanning a log file.
+type scanResult struct {
+	// size is the offset at which the last complete record ends.
+	size int64
+	// torn is true if the file ends with a record that was only partially written.
+	torn bool
+	// corrupted holds the records that failed their checksum.
+	corrupted []*CorruptRecordError
+}
+
+// resync searches for the first intact record that follows a corrupt header.
+// It returns the end of the file if there aren't any records left.
+func resync(r io.ReaderAt, offset, end int64) int64 {
+	for next := offset + 1; next+headerSize <= end; next++ {
+		if _, _, err := readRecord(r, next, end); err == nil {
+			return next
+		}
+	}
+	return end
+}
 
-	file, err := os.Open(filepath)
+// scan reads a log file and calls fn for each intact record along with its
+// offset. The records of a batch are passed one by one, with the offset of
+// the frame that holds them. Records that fail their checksum are reported in
+// the result rather than passed to fn. If the header of a record is corrupt
+// its length can't be trusted, and the scan resumes at the next intact record.
+// The scan stops at the first record that is incomplete, which is how a write
+// that was interrupted by a crash is going to look. The same goes for a corrupt
+// header that isn't followed by any intact records.
+func scan(path string, fn func(RecordWithOffset)) (scanResult, error) {
+	file, err := os.Open(path)
 	if err != nil {
-		close(ch)
-		return ch
+		return scanResult{}, err
 	}
+	defer file.Close()
 
-	go func() {
-		defer close(ch)
-		defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return scanResult{}, err
+	}
+
+	// The records follow the magic at the start of the segment.
+	result := scanResult{size: int64(len(segmentMagic))}
+	end := info.Size()
+	for result.size < end {
+		offset := result.size
+		record, size, readErr := readRecord(file, offset, end)
+		if errors.Is(readErr, ErrTornRecord) {
+			result.torn = true
+			return result, nil
+		}
 
-		var currentOffset int64
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			var record Record
-			if unmarshalErr := json.Unmarshal(scanner.Bytes(), &record); unmarshalErr != nil {
-				continue
+		// If the last record of the file fails its checksum it's most likely a write
+		// that was interrupted halfway through. We'll treat it as a torn tail.
+		if errors.Is(readErr, ErrChecksumMismatch) && offset+size == end {
+			result.torn = true
+			return result, nil
+		}
+
+		if errors.Is(readErr, ErrCorruptHeader) {
+			// If there isn't an intact record after the corrupt header, the file ends with
+			// garbage. A loss of power often leaves the tail of a file filled with zeros.
+			// We'll treat it as a torn tail, so that new records aren't appended after it.
+			next := resync(file, offset, end)
+			if next == end {
+				result.torn = true
+				return result, nil
+			}
+			result.corrupted = append(result.corrupted, &CorruptRecordError{
+				Segment: filepath.Base(path),
+				Offset:  offset,
+				Err:     readErr,
+			})
+			result.size = next
+			continue
+		}
+
+		if readErr != nil {
+			if size == 0 {
+				return result, readErr
 			}
-			ch <- RecordWithOffset{record, currentOffset}
-			currentOffset += int64(len(scanner.Bytes()) + len("\n"))
+			result.corrupted = append(result.corrupted, &CorruptRecordError{
+				Segment: filepath.Base(path),
+				Offset:  offset,
+				Err:     readErr,
+			})
+			result.size += size
+			continue
+		}
+
+		for _, entry := range record.entries() {
+			fn(RecordWithOffset{entry, offset})
 		}
-	}()
+		result.size += size
+	}
 
-	return ch
+	return result, nil
 }
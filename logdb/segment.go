@@ -1,7 +1,7 @@
 package logdb
 
 import (
-	"encoding/json"
+	"errors"
 	"io"
 	"os"
 	"path"
@@ -20,7 +20,22 @@ type Segment struct {
 	prev      *Segment
 	next      *Segment
 	hashIndex HashIndex
-	logFile   *os.File
+	// tombstones holds the keys whose most recent value in this segment is a tombstone.
+	tombstones map[string]struct{}
+	// operands holds the offsets of the merge operands that have been written
+	// since the most recent value of the key. Keys that only have operands in
+	// this segment are kept in partial, and their index points to the first one.
+	operands map[string][]int64
+	partial  map[string]struct{}
+	logFile  *os.File
+}
+
+// entry is the state of a key in a single segment.
+type entry struct {
+	value    []byte
+	operands [][]byte
+	deleted  bool
+	partial  bool
 }
 
 // newSegment creates a new segment with the given index.
@@ -31,59 +46,104 @@ func newSegment(dirpath string, segmentIndex int) *Segment {
 		panic(err)
 	}
 
-	newSegment := &Segment{
-		index:     segmentIndex,
-		hashIndex: make(HashIndex),
-		logFile:   file,
+	if _, err = file.WriteString(segmentMagic); err != nil {
+		panic(err)
 	}
 
-	return newSegment
+	segment := emptySegment(segmentIndex, file)
+	segment.bytes = int64(len(segmentMagic))
+	return segment
 }
 
-// get retrieves a value from the segment.
-func (s *Segment) get(key string) ([]byte, bool) {
-	s.Lock()
-	defer s.Unlock()
-
-	offset, ok := s.hashIndex[key]
-	if !ok {
-		return nil, false
+// emptySegment returns a segment with an empty index.
+func emptySegment(segmentIndex int, file *os.File) *Segment {
+	return &Segment{
+		index:      segmentIndex,
+		hashIndex:  make(HashIndex),
+		tombstones: make(map[string]struct{}),
+		operands:   make(map[string][]int64),
+		partial:    make(map[string]struct{}),
+		logFile:    file,
 	}
-	_, err := s.logFile.Seek(offset, io.SeekStart)
+}
+
+// read returns the value of the key from the record at the given offset.
+// should be called with a lock.
+func (s *Segment) read(key string, offset int64) ([]byte, bool) {
+	record, _, err := readRecord(s.logFile, offset, s.bytes)
 	if err != nil {
 		return nil, false
 	}
 
-	var record Record
-	decoder := json.NewDecoder(s.logFile)
-	if decodeErr := decoder.Decode(&record); decodeErr != nil {
+	r, ok := record.lookup(key)
+	if !ok {
 		return nil, false
 	}
-
-	return record.Value, true
+	return r.Value, true
 }
 
-func (s *Segment) getNoLock(key string) ([]byte, bool) {
+// entry returns the value of the key in this segment, along with
+// the operands that have been merged into it since it was written.
+func (s *Segment) entry(key string) (entry, bool) {
+	s.Lock()
+	defer s.Unlock()
+
 	offset, ok := s.hashIndex[key]
 	if !ok {
-		return nil, false
+		return entry{}, false
 	}
-	_, err := s.logFile.Seek(offset, io.SeekStart)
-	if err != nil {
-		return nil, false
+
+	var e entry
+	_, e.deleted = s.tombstones[key]
+	_, e.partial = s.partial[key]
+	if !e.partial && !e.deleted {
+		if e.value, ok = s.read(key, offset); !ok {
+			return entry{}, false
+		}
 	}
 
-	var record Record
-	decoder := json.NewDecoder(s.logFile)
-	if decodeErr := decoder.Decode(&record); decodeErr != nil {
-		return nil, false
+	for _, operandOffset := range s.operands[key] {
+		record, _, err := readRecord(s.logFile, operandOffset, s.bytes)
+		if err != nil {
+			return entry{}, false
+		}
+		e.operands = append(e.operands, record.operands(key)...)
 	}
+	return e, true
+}
 
-	return record.Value, true
+// hasValue returns true if the segment holds a value, or
+// a tombstone, for the key which the operands build upon.
+func (s *Segment) hasValue(key string) bool {
+	s.Lock()
+	defer s.Unlock()
+	_, ok := s.hashIndex[key]
+	_, partial := s.partial[key]
+	return ok && !partial
 }
 
 // set writes a key-value pair to the segments log file.
 func (s *Segment) set(key string, value []byte) error {
+	return s.write(Record{Key: key, Value: value})
+}
+
+// setTombstone writes a record to the segments log file which marks the key as deleted.
+func (s *Segment) setTombstone(key string) error {
+	return s.write(Record{Key: key, Tombstone: true})
+}
+
+// setBatch writes the records to the segments log file as a single frame.
+func (s *Segment) setBatch(records []Record) error {
+	return s.write(Record{Batch: records})
+}
+
+// setOperand writes a merge operand for the key to the segments log file.
+func (s *Segment) setOperand(key string, operand []byte) error {
+	return s.write(Record{Key: key, Value: operand, Operand: true})
+}
+
+// write appends the record to the segments log file, and updates the index.
+func (s *Segment) write(record Record) error {
 	s.Lock()
 	defer s.Unlock()
 
@@ -92,23 +152,50 @@ func (s *Segment) set(key string, value []byte) error {
 		return err
 	}
 
-	r := Record{Key: key, Value: value}
-	bytes, err := json.Marshal(r)
+	bytes, err := encodeRecord(record)
 	if err != nil {
 		return err
 	}
 
-	bytes = append(bytes, '\n')
 	_, err = s.logFile.Write(bytes)
 	if err != nil {
-		return err
+		// Remove whatever part of the record that made it to disk. Otherwise,
+		// the next record would be appended after a partially written one.
+		return errors.Join(err, s.logFile.Truncate(offset))
+	}
+	for _, r := range record.entries() {
+		s.indexRecord(r, offset)
 	}
-	s.hashIndex[key] = offset
 	s.bytes = offset + int64(len(bytes))
 
 	return nil
 }
 
+// indexRecord adds a record that was written at the given offset to the index.
+// should be called with a lock.
+func (s *Segment) indexRecord(record Record, offset int64) {
+	if record.Operand {
+		if _, ok := s.hashIndex[record.Key]; !ok {
+			s.hashIndex[record.Key] = offset
+			s.partial[record.Key] = struct{}{}
+		}
+		// A batch can hold several operands for the key, which are all read from the same offset.
+		if operands := s.operands[record.Key]; len(operands) == 0 || operands[len(operands)-1] != offset {
+			s.operands[record.Key] = append(operands, offset)
+		}
+		return
+	}
+
+	s.hashIndex[record.Key] = offset
+	delete(s.operands, record.Key)
+	delete(s.partial, record.Key)
+	if record.Tombstone {
+		s.tombstones[record.Key] = struct{}{}
+	} else {
+		delete(s.tombstones, record.Key)
+	}
+}
+
 // size returns the size of the segment in bytes.
 func (s *Segment) size() int64 {
 	s.Lock()
@@ -120,5 +207,8 @@ func (s *Segment) size() int64 {
 // should be called with a lock.
 func (s *Segment) delete() error {
 	s.logFile.Close()
+	if err := removeHint(s.logFile.Name()); err != nil {
+		return err
+	}
 	return os.Remove(s.logFile.Name())
 }
@@ -0,0 +1,192 @@
+package logdb
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/viccon/pulse/fsutil"
+)
+
+var (
+	ErrNoSnapshot = errors.New("there isn't a pending snapshot")
+	// errCorruptSnapshotIndex is returned if the file that tracks the pending snapshot can't be parsed.
+	errCorruptSnapshotIndex = errors.New("the snapshot index is corrupt")
+)
+
+// snapshotFilename is the name of the file that keeps track of which
+// segments belong to a snapshot that hasn't been acknowledged yet. The first
+// line holds the index of the newest segment in the snapshot, and each of the
+// following lines holds a checkpoint.
+const snapshotFilename = "snapshot"
+
+// readSnapshotIndex returns the index of the newest segment that is part of
+// the pending snapshot, along with its checkpoints. It returns -1 if there
+// isn't a pending snapshot, or if the file is empty or can't be parsed.
+func readSnapshotIndex(dirPath string) (int, []string, error) {
+	bytes, err := os.ReadFile(path.Join(dirPath, snapshotFilename))
+	if errors.Is(err, fs.ErrNotExist) {
+		return -1, nil, nil
+	}
+	if err != nil {
+		return -1, nil, err
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(bytes)), "\n")
+	index, err := strconv.Atoi(lines[0])
+	if err != nil {
+		return -1, nil, fmt.Errorf("%w: %w", errCorruptSnapshotIndex, err)
+	}
+	return index, lines[1:], nil
+}
+
+// writeSnapshotIndex persists the index of the newest segment in the snapshot,
+// along with its checkpoints.
+func writeSnapshotIndex(dirPath string, index int, checkpoints []string) error {
+	lines := append([]string{strconv.Itoa(index)}, checkpoints...)
+	return fsutil.WriteFileAtomic(path.Join(dirPath, snapshotFilename), []byte(strings.Join(lines, "\n")), 0o600)
+}
+
+// removeSnapshotIndex removes the file that tracks the pending snapshot.
+func removeSnapshotIndex(dirPath string) error {
+	err := os.Remove(path.Join(dirPath, snapshotFilename))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// uniqueValues returns the most recent value for every key in the segments,
+// with its operands folded into it. Keys that have been deleted are left out.
+// The segments are expected to be ordered from newest to oldest. Keys whose
+// operands can't be merged are left out, and reported through the error.
+func uniqueValues(segments []*Segment, merge MergeFunc) (map[string][]byte, error) {
+	values := make(map[string][]byte)
+	seen := make(map[string]struct{})
+	var errs []error
+	for _, segment := range segments {
+		for key := range segment.hashIndex {
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			value, ok, err := resolve(segments, key, merge)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+				continue
+			}
+			if ok {
+				values[key] = value
+			}
+		}
+	}
+	return values, errors.Join(errs...)
+}
+
+// snapshotValues returns the unique values of the snapshot. Keys whose operands
+// can't be merged are reported, rather than failing the entire snapshot.
+func (db *LogDB) snapshotValues() map[string][]byte {
+	values, err := uniqueValues(db.snapshot, db.merge)
+	if err != nil {
+		db.log.Error("Failed to merge the operands", "err", err)
+	}
+	return values
+}
+
+// Snapshot seals every segment in the database, and returns the unique
+// key-value pairs that they contain. Writes that happen after the snapshot
+// was taken are going to start from an empty database. The sealed segments
+// are kept on disk until the snapshot is acknowledged. If the previous
+// snapshot was never acknowledged, its values are returned again instead.
+func (db *LogDB) Snapshot() (map[string][]byte, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	if len(db.snapshot) > 0 {
+		db.log.Info("Returning the values of a pending snapshot")
+		return db.snapshotValues(), nil
+	}
+
+	if len(db.head.hashIndex) == 0 && db.tail == nil {
+		return make(map[string][]byte), nil
+	}
+
+	db.log.Info("Taking a snapshot of the segments")
+	err := writeSnapshotIndex(db.dirPath, db.head.index, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Detach every segment from the linked list, ordered from newest to oldest.
+	segments := db.segments()
+	for _, segment := range segments {
+		segment.next, segment.prev = nil, nil
+	}
+
+	// The head is sealed along with the other segments of the snapshot.
+	if err = segments[0].writeHint(); err != nil {
+		db.log.Error("Failed to write the hint file", "err", err)
+	}
+
+	db.snapshot = segments
+	db.head, db.tail = newSegment(db.dirPath, segments[0].index+1), nil
+	return db.snapshotValues(), nil
+}
+
+// Acknowledge should be called once the values of the snapshot have been
+// persisted elsewhere. It removes the sealed segments from disk.
+func (db *LogDB) Acknowledge() error {
+	db.Lock()
+	defer db.Unlock()
+
+	for len(db.snapshot) > 0 {
+		if err := db.snapshot[0].delete(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		db.snapshot = db.snapshot[1:]
+	}
+
+	db.log.Info("The snapshot was acknowledged")
+	clear(db.checkpoints)
+	return removeSnapshotIndex(db.dirPath)
+}
+
+// Checkpoint records that part of the pending snapshot, such as the values of
+// a single day, has been persisted elsewhere. The checkpoints are kept on disk
+// until the snapshot is acknowledged, which allows the parts that have already
+// been persisted to be skipped if the snapshot is retried after a restart.
+func (db *LogDB) Checkpoint(name string) error {
+	db.Lock()
+	defer db.Unlock()
+
+	if len(db.snapshot) == 0 {
+		return ErrNoSnapshot
+	}
+	if strings.ContainsRune(name, '\n') {
+		return fmt.Errorf("invalid checkpoint name: %q", name)
+	}
+
+	checkpoints := make([]string, 0, len(db.checkpoints)+1)
+	for checkpoint := range db.checkpoints {
+		checkpoints = append(checkpoints, checkpoint)
+	}
+	checkpoints = append(checkpoints, name)
+	if err := writeSnapshotIndex(db.dirPath, db.snapshot[0].index, checkpoints); err != nil {
+		return err
+	}
+	db.checkpoints[name] = struct{}{}
+	return nil
+}
+
+// Checkpointed returns true if the checkpoint has been
+// recorded for the pending snapshot.
+func (db *LogDB) Checkpointed(name string) bool {
+	db.RLock()
+	defer db.RUnlock()
+	_, ok := db.checkpoints[name]
+	return ok
+}
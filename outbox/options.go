@@ -0,0 +1,41 @@
+package outbox
+
+import (
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/viccon/pulse/clock"
+)
+
+type Option func(*Outbox)
+
+// WithClock sets the clock used by the outbox.
+func WithClock(clock clock.Clock) Option {
+	return func(o *Outbox) {
+		o.clock = clock
+	}
+}
+
+// WithLog sets the logger used by the outbox.
+func WithLog(log *log.Logger) Option {
+	return func(o *Outbox) {
+		o.logger = log
+	}
+}
+
+// WithBackoff sets the minimum and maximum amount of time that
+// the outbox waits before retrying a write that has failed.
+func WithBackoff(minBackoff, maxBackoff time.Duration) Option {
+	return func(o *Outbox) {
+		o.minBackoff = minBackoff
+		o.maxBackoff = maxBackoff
+	}
+}
+
+// WithMaxAttempts sets the number of times that a session can be refused
+// by the remote storage before it's moved to the dead-letter directory.
+func WithMaxAttempts(maxAttempts int) Option {
+	return func(o *Outbox) {
+		o.maxAttempts = maxAttempts
+	}
+}
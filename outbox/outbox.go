@@ -0,0 +1,239 @@
+// Package outbox persists coding sessions on disk until
+// they have been successfully written to the remote storage.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/viccon/pulse"
+	"github.com/viccon/pulse/clock"
+	"github.com/viccon/pulse/fsutil"
+	"github.com/viccon/pulse/logger"
+)
+
+const (
+	defaultMinBackoff  = time.Second * 5
+	defaultMaxBackoff  = time.Hour
+	defaultMaxAttempts = 5
+)
+
+// deadLetterDirname is the name of the directory, within the outbox, that
+// holds the sessions which the remote storage has refused too many times.
+const deadLetterDirname = "dead"
+
+// SessionWriter is the remote storage that the outbox delivers sessions to.
+type SessionWriter interface {
+	Write(context.Context, pulse.CodingSession) error
+}
+
+// Outbox implements the SessionWriter interface. Every session is written to
+// disk, and then delivered to the remote storage in the background. Sessions
+// that fail to be delivered are retried with an exponential backoff. A session
+// that keeps failing while the remote storage accepts other sessions is moved
+// to the dead-letter directory, so that it can't hold up the ones behind it.
+type Outbox struct {
+	mu          sync.Mutex
+	dirPath     string
+	remote      SessionWriter
+	clock       clock.Clock
+	logger      *log.Logger
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	maxAttempts int
+	attempts    map[string]int
+	lastID      int64
+	notify      chan struct{}
+}
+
+// New creates a new outbox that stores its sessions in the given directory.
+func New(dirPath string, remote SessionWriter, opts ...Option) (*Outbox, error) {
+	o := &Outbox{
+		dirPath:     dirPath,
+		remote:      remote,
+		clock:       clock.New(),
+		logger:      logger.New(),
+		minBackoff:  defaultMinBackoff,
+		maxBackoff:  defaultMaxBackoff,
+		maxAttempts: defaultMaxAttempts,
+		attempts:    make(map[string]int),
+		notify:      make(chan struct{}, 1),
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if err := os.MkdirAll(path.Join(dirPath, deadLetterDirname), 0o755); err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// nextFilename returns a unique filename for a session. The
+// filenames sort in the order that the sessions were written.
+func (o *Outbox) nextFilename() string {
+	id := o.clock.Now().UnixNano()
+	if id <= o.lastID {
+		id = o.lastID + 1
+	}
+	o.lastID = id
+	return fmt.Sprintf("%020d.json", id)
+}
+
+// Write persists the session to disk. The session is going to
+// be delivered to the remote storage by the background job.
+func (o *Outbox) Write(_ context.Context, session pulse.CodingSession) error {
+	bytes, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	filename := o.nextFilename()
+	o.mu.Unlock()
+
+	// The session is written atomically, which means that we never attempt
+	// to deliver one that is incomplete, and drop it for being undecodable.
+	if err = fsutil.WriteFileAtomic(path.Join(o.dirPath, filename), bytes, 0o600); err != nil {
+		return err
+	}
+
+	select {
+	case o.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// pending returns the paths of the sessions that haven't been delivered yet.
+func (o *Outbox) pending() ([]string, error) {
+	entries, err := os.ReadDir(o.dirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		paths = append(paths, path.Join(o.dirPath, entry.Name()))
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// deliver reads the session at the path and writes it to the remote storage.
+// The session is removed from the outbox once it has been delivered.
+func (o *Outbox) deliver(ctx context.Context, p string) error {
+	bytes, err := os.ReadFile(p)
+	if err != nil {
+		return err
+	}
+
+	var session pulse.CodingSession
+	if unmarshalErr := json.Unmarshal(bytes, &session); unmarshalErr != nil {
+		o.logger.Error("Removing a session that can't be decoded", "path", p, "err", unmarshalErr)
+		return os.Remove(p)
+	}
+
+	if err = o.remote.Write(ctx, session); err != nil {
+		return err
+	}
+	if err = os.Remove(p); err != nil {
+		return err
+	}
+	o.logger.Debug("Delivered a session from the outbox", "path", p)
+	return nil
+}
+
+// deadLetter moves a session that has been refused too many times to the
+// dead-letter directory. It can be moved back to the outbox to be retried.
+func (o *Outbox) deadLetter(p string) error {
+	o.logger.Error("Moving a session that keeps failing to the dead-letter directory",
+		"path", p,
+		"attempts", o.attempts[p],
+	)
+	delete(o.attempts, p)
+	return os.Rename(p, path.Join(o.dirPath, deadLetterDirname, filepath.Base(p)))
+}
+
+// flush delivers every pending session to the remote storage, in the order
+// that they were written. A failed delivery doesn't stop the sessions behind
+// it from being delivered. The failure only counts as an attempt if another
+// session was delivered during the same flush. Otherwise, the remote storage
+// is most likely unavailable, and we don't want an outage to exhaust the
+// attempts of every session.
+func (o *Outbox) flush(ctx context.Context) error {
+	paths, err := o.pending()
+	if err != nil {
+		return err
+	}
+
+	var delivered bool
+	failed := make(map[string]error)
+	for _, p := range paths {
+		if deliverErr := o.deliver(ctx, p); deliverErr != nil {
+			failed[p] = deliverErr
+			continue
+		}
+		delete(o.attempts, p)
+		delivered = true
+	}
+
+	errs := make([]error, 0, len(failed))
+	for p, deliverErr := range failed {
+		if delivered {
+			o.attempts[p]++
+		}
+		if o.attempts[p] < o.maxAttempts {
+			errs = append(errs, deliverErr)
+			continue
+		}
+		if deadLetterErr := o.deadLetter(p); deadLetterErr != nil {
+			errs = append(errs, deadLetterErr)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run delivers the sessions in the outbox until the context is cancelled.
+// Sessions that were left in the outbox by a previous process are delivered
+// straight away. If a delivery fails, we'll wait for an exponentially
+// increasing amount of time before the next attempt.
+func (o *Outbox) Run(ctx context.Context) {
+	backoff := o.minBackoff
+	for {
+		err := o.flush(ctx)
+		if err == nil {
+			backoff = o.minBackoff
+			select {
+			case <-ctx.Done():
+				return
+			case <-o.notify:
+			}
+			continue
+		}
+
+		o.logger.Errorf("Failed to deliver the sessions in the outbox. Retrying in %s: %v", backoff, err)
+		timer, stopTimer := o.clock.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			stopTimer()
+			return
+		case <-timer:
+		}
+		backoff = min(backoff*2, o.maxBackoff)
+	}
+}
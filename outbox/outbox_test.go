@@ -0,0 +1,211 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/viccon/pulse"
+	"github.com/viccon/pulse/clock"
+	"github.com/viccon/pulse/outbox"
+)
+
+type mockStorage struct {
+	sync.Mutex
+	err      error
+	attempts int
+	sessions []pulse.CodingSession
+	// rejected is the duration of the sessions that always fail to be written.
+	rejected time.Duration
+}
+
+func (m *mockStorage) Write(_ context.Context, session pulse.CodingSession) error {
+	m.Lock()
+	defer m.Unlock()
+	m.attempts++
+	if m.err != nil {
+		return m.err
+	}
+	if m.rejected != 0 && session.Duration == m.rejected {
+		return errors.New("the session was rejected")
+	}
+	m.sessions = append(m.sessions, session)
+	return nil
+}
+
+func (m *mockStorage) SetErr(err error) {
+	m.Lock()
+	defer m.Unlock()
+	m.err = err
+}
+
+func (m *mockStorage) Attempts() int {
+	m.Lock()
+	defer m.Unlock()
+	return m.attempts
+}
+
+func (m *mockStorage) GetSessions() []pulse.CodingSession {
+	m.Lock()
+	defer m.Unlock()
+	return m.sessions
+}
+
+func newSession(date time.Time, duration time.Duration) pulse.CodingSession {
+	return pulse.CodingSession{
+		Date:     date,
+		Duration: duration,
+		Repositories: pulse.Repositories{
+			{Name: "pulse", Duration: duration},
+		},
+	}
+}
+
+func TestOutboxRetriesWithBackoff(t *testing.T) {
+	t.Parallel()
+
+	mockClock := clock.NewMock(time.Now())
+	storage := &mockStorage{err: errors.New("the remote storage is unavailable")}
+	o, err := outbox.New(t.TempDir(), storage,
+		outbox.WithClock(mockClock),
+		outbox.WithLog(log.New(io.Discard)),
+		outbox.WithBackoff(time.Second, time.Minute),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go o.Run(ctx)
+
+	err = o.Write(ctx, newSession(mockClock.Now(), time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if attempts := storage.Attempts(); attempts != 1 {
+		t.Fatalf("expected 1 attempt; got %d", attempts)
+	}
+
+	// The first retry should happen after one second.
+	mockClock.Add(time.Second)
+	time.Sleep(50 * time.Millisecond)
+	if attempts := storage.Attempts(); attempts != 2 {
+		t.Fatalf("expected 2 attempts; got %d", attempts)
+	}
+
+	// The backoff should have doubled.
+	mockClock.Add(time.Second)
+	time.Sleep(50 * time.Millisecond)
+	if attempts := storage.Attempts(); attempts != 2 {
+		t.Fatalf("expected 2 attempts; got %d", attempts)
+	}
+
+	storage.SetErr(nil)
+	mockClock.Add(time.Second)
+	time.Sleep(50 * time.Millisecond)
+	if sessions := storage.GetSessions(); len(sessions) != 1 {
+		t.Fatalf("expected 1 session; got %d", len(sessions))
+	}
+}
+
+func TestOutboxSurvivesRestarts(t *testing.T) {
+	t.Parallel()
+
+	dirPath := t.TempDir()
+	failingStorage := &mockStorage{err: errors.New("the remote storage is unavailable")}
+	o, err := outbox.New(dirPath, failingStorage, outbox.WithLog(log.New(io.Discard)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		err = o.Write(context.Background(), newSession(now, time.Duration(i+1)*time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A new outbox in the same directory should deliver the sessions in the order they were written.
+	storage := &mockStorage{}
+	restoredOutbox, err := outbox.New(dirPath, storage, outbox.WithLog(log.New(io.Discard)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go restoredOutbox.Run(ctx)
+	time.Sleep(50 * time.Millisecond)
+
+	sessions := storage.GetSessions()
+	if len(sessions) != 3 {
+		t.Fatalf("expected 3 sessions; got %d", len(sessions))
+	}
+	for i, session := range sessions {
+		if session.Duration != time.Duration(i+1)*time.Minute {
+			t.Errorf("expected session %d to be %v; got %v", i, time.Duration(i+1)*time.Minute, session.Duration)
+		}
+	}
+}
+
+func TestOutboxMovesRejectedSessionsToDeadLetters(t *testing.T) {
+	t.Parallel()
+
+	dirPath := t.TempDir()
+	mockClock := clock.NewMock(time.Now())
+	storage := &mockStorage{rejected: time.Hour}
+	o, err := outbox.New(dirPath, storage,
+		outbox.WithClock(mockClock),
+		outbox.WithLog(log.New(io.Discard)),
+		outbox.WithBackoff(time.Second, time.Minute),
+		outbox.WithMaxAttempts(2),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first session is always rejected, and shouldn't block the ones behind it.
+	for _, duration := range []time.Duration{time.Hour, time.Minute, 2 * time.Minute} {
+		if err = o.Write(context.Background(), newSession(mockClock.Now(), duration)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go o.Run(ctx)
+	time.Sleep(50 * time.Millisecond)
+	if sessions := storage.GetSessions(); len(sessions) != 2 {
+		t.Fatalf("expected 2 sessions; got %d", len(sessions))
+	}
+
+	// The rejected session has its second attempt when the next one is delivered.
+	if err = o.Write(ctx, newSession(mockClock.Now(), 3*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	mockClock.Add(time.Second)
+	time.Sleep(50 * time.Millisecond)
+	if sessions := storage.GetSessions(); len(sessions) != 3 {
+		t.Fatalf("expected 3 sessions; got %d", len(sessions))
+	}
+
+	deadLetters, err := filepath.Glob(filepath.Join(dirPath, "dead", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending, err := filepath.Glob(filepath.Join(dirPath, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deadLetters) != 1 || len(pending) != 0 {
+		t.Errorf("expected the rejected session to be moved to the dead letters; got %d dead letters and %d pending", len(deadLetters), len(pending))
+	}
+}
@@ -16,8 +16,12 @@ call remote#host#RegisterPlugin('pulse-client', '0', [
 			\ {'type': 'function', 'name': 'OpenFile', 'sync': 1, 'opts': {}},
 			\ {'type': 'function', 'name': 'SendHeartbeat', 'sync': 1, 'opts': {}},
 			\ {'type': 'function', 'name': 'EndSession', 'sync': 1, 'opts': {}},
+			\ {'type': 'function', 'name': 'Today', 'sync': 1, 'opts': {}},
 			\ ])
 
+" Shows how much time has been spent in each repository today.
+command! PulseToday echo call("Today", [g:pulse_session_id, expand('%:p'), &filetype])
+
 
 " We only want track time for one instance nvim instance. We
 " need to let the server know which one we have focused.
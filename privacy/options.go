@@ -0,0 +1,20 @@
+package privacy
+
+import "strings"
+
+type Option func(*Writer)
+
+// WithMode sets the mode of the repositories that haven't been configured.
+func WithMode(mode Mode) Option {
+	return func(w *Writer) {
+		w.mode = mode
+	}
+}
+
+// WithRepositoryMode sets the mode of a repository. The repository
+// is either its fully qualified identity, or its name.
+func WithRepositoryMode(repository string, mode Mode) Option {
+	return func(w *Writer) {
+		w.repositories[strings.ToLower(repository)] = mode
+	}
+}
@@ -0,0 +1,200 @@
+// Package privacy redacts the file paths of coding sessions
+// before they are written to the remote storage.
+package privacy
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/viccon/pulse"
+	"github.com/viccon/pulse/fsutil"
+)
+
+// Mode determines how much of a file path is kept.
+type Mode string
+
+const (
+	// ModeKeep keeps the file paths as they are.
+	ModeKeep Mode = "keep"
+	// ModeDirectories keeps the directory of each file, but not its name.
+	ModeDirectories Mode = "directories"
+	// ModeHash replaces the path of each file with a salted hash. The same
+	// path always results in the same hash, which keeps the totals per file.
+	ModeHash Mode = "hash"
+	// ModeDrop removes every file, and keeps one entry for each filetype.
+	ModeDrop Mode = "drop"
+)
+
+// saltSize is the number of random bytes in a salt.
+const saltSize = 32
+
+// ParseMode validates the mode. An empty string defaults to ModeKeep.
+func ParseMode(s string) (Mode, error) {
+	switch mode := Mode(s); mode {
+	case "":
+		return ModeKeep, nil
+	case ModeKeep, ModeDirectories, ModeHash, ModeDrop:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid privacy mode: %q", s)
+	}
+}
+
+// LoadSalt reads the salt from the file. If the file doesn't exist, a new
+// salt is generated and written to it. The salt never leaves the machine,
+// which prevents the hashes from being reversed by guessing common paths. A
+// salt of the wrong size is rejected, rather than used to compute hashes that
+// are easier to reverse.
+func LoadSalt(filename string) ([]byte, error) {
+	salt, err := os.ReadFile(filename)
+	if err == nil {
+		if len(salt) != saltSize {
+			return nil, fmt.Errorf("invalid salt in %s: expected %d bytes, got %d", filename, saltSize, len(salt))
+		}
+		return salt, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	salt = make([]byte, saltSize)
+	if _, err = rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(path.Dir(filename), 0o755); err != nil {
+		return nil, err
+	}
+	if err = fsutil.WriteFileAtomic(filename, salt, 0o600); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// SessionWriter is the storage that the redacted sessions are written to.
+type SessionWriter interface {
+	Write(context.Context, pulse.CodingSession) error
+}
+
+// Writer implements the SessionWriter interface. It redacts
+// the files of every session before passing it along.
+type Writer struct {
+	next         SessionWriter
+	salt         []byte
+	mode         Mode
+	repositories map[string]Mode
+}
+
+// New creates a new writer that hashes paths with the given salt.
+func New(next SessionWriter, salt []byte, opts ...Option) *Writer {
+	w := &Writer{
+		next:         next,
+		salt:         salt,
+		mode:         ModeKeep,
+		repositories: make(map[string]Mode),
+	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	return w
+}
+
+// modeFor returns the mode of the repository. A repository can be
+// configured by either its fully qualified identity or its name.
+func (w *Writer) modeFor(repo pulse.Repository) Mode {
+	if mode, ok := w.repositories[strings.ToLower(repo.ID)]; ok && repo.ID != "" {
+		return mode
+	}
+	if mode, ok := w.repositories[strings.ToLower(repo.Name)]; ok {
+		return mode
+	}
+	return w.mode
+}
+
+// hash returns a salted hash of the path.
+func (w *Writer) hash(p string) string {
+	mac := hmac.New(sha256.New, w.salt)
+	mac.Write([]byte(p))
+	return hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// redactFile returns the file with the parts that the mode doesn't keep removed.
+func (w *Writer) redactFile(repo pulse.Repository, file pulse.File, mode Mode) pulse.File {
+	// The path of a file is prefixed with the name of the repository.
+	relativePath := strings.TrimPrefix(file.Path, repo.Name+"/")
+
+	switch mode {
+	case ModeDirectories:
+		dir := path.Dir(relativePath)
+		return pulse.File{Path: path.Join(repo.Name, dir), Filetype: file.Filetype, Duration: file.Duration}
+	case ModeHash:
+		hash := w.hash(relativePath)
+		return pulse.File{Name: hash, Path: path.Join(repo.Name, hash), Filetype: file.Filetype, Duration: file.Duration}
+	case ModeDrop:
+		return pulse.File{Filetype: file.Filetype, Duration: file.Duration}
+	default:
+		return file
+	}
+}
+
+// redactFiles redacts every file of the repository.
+func (w *Writer) redactFiles(repo pulse.Repository, mode Mode) pulse.Files {
+	files := make(pulse.Files, 0, len(repo.Files))
+	for _, file := range repo.Files {
+		files = append(files, w.redactFile(repo, file, mode))
+	}
+	return files
+}
+
+// mergeFiles combines the files that share a path and filetype
+// once they have been redacted, e.g. files in the same directory.
+func mergeFiles(files pulse.Files) pulse.Files {
+	type key struct{ path, filetype string }
+	indexes := make(map[key]int)
+	merged := make(pulse.Files, 0, len(files))
+	for _, file := range files {
+		k := key{file.Path, file.Filetype}
+		if i, ok := indexes[k]; ok {
+			merged[i].Duration += file.Duration
+			continue
+		}
+		indexes[k] = len(merged)
+		merged = append(merged, file)
+	}
+	return merged
+}
+
+// Redact applies the privacy mode of each repository to its files. The
+// durations of the repositories and filetypes are left untouched.
+func (w *Writer) Redact(session pulse.CodingSession) pulse.CodingSession {
+	repositories := make(pulse.Repositories, 0, len(session.Repositories))
+	for _, repo := range session.Repositories {
+		mode := w.modeFor(repo)
+		if mode == ModeKeep {
+			repositories = append(repositories, repo)
+			continue
+		}
+
+		redacted := repo
+		redacted.Files = mergeFiles(w.redactFiles(repo, mode))
+		repositories = append(repositories, redacted)
+	}
+
+	session.Repositories = repositories
+	return session
+}
+
+// Write redacts the session, and writes it to the next writer.
+func (w *Writer) Write(ctx context.Context, session pulse.CodingSession) error {
+	return w.next.Write(ctx, w.Redact(session))
+}
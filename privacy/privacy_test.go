@@ -0,0 +1,151 @@
+package privacy_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/viccon/pulse"
+	"github.com/viccon/pulse/privacy"
+)
+
+type mockWriter struct {
+	sessions []pulse.CodingSession
+}
+
+func (m *mockWriter) Write(_ context.Context, session pulse.CodingSession) error {
+	m.sessions = append(m.sessions, session)
+	return nil
+}
+
+func newSession() pulse.CodingSession {
+	files := pulse.Files{
+		{Name: "main.go", Path: "api/cmd/main.go", Filetype: "go", Duration: 10 * time.Minute},
+		{Name: "flags.go", Path: "api/cmd/flags.go", Filetype: "go", Duration: 5 * time.Minute},
+		{Name: "README.md", Path: "api/README.md", Filetype: "markdown", Duration: 3 * time.Minute},
+	}
+	return pulse.CodingSession{
+		Duration: 18 * time.Minute,
+		Repositories: pulse.Repositories{
+			{ID: "github.com/acme/api", Name: "api", Files: files, Duration: 18 * time.Minute},
+		},
+	}
+}
+
+func paths(files pulse.Files) []string {
+	p := make([]string, 0, len(files))
+	for _, file := range files {
+		p = append(p, file.Path)
+	}
+	slices.Sort(p)
+	return p
+}
+
+func TestModes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		mode     privacy.Mode
+		expected []string
+	}{
+		{mode: privacy.ModeKeep, expected: []string{"api/README.md", "api/cmd/flags.go", "api/cmd/main.go"}},
+		{mode: privacy.ModeDirectories, expected: []string{"api", "api/cmd"}},
+		{mode: privacy.ModeDrop, expected: []string{"", ""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			t.Parallel()
+
+			w := privacy.New(&mockWriter{}, []byte("salt"), privacy.WithRepositoryMode("github.com/acme/api", tt.mode))
+			session := w.Redact(newSession())
+			repo := session.Repositories[0]
+			if got := paths(repo.Files); !slices.Equal(got, tt.expected) {
+				t.Errorf("expected the paths %v; got %v", tt.expected, got)
+			}
+			if repo.Duration != 18*time.Minute || repo.Name != "api" {
+				t.Errorf("expected the repository totals to be preserved; got %+v", repo)
+			}
+
+			// The time spent on each language should be preserved.
+			var goDuration time.Duration
+			for _, file := range repo.Files {
+				if file.Filetype == "go" {
+					goDuration += file.Duration
+				}
+			}
+			if goDuration != 15*time.Minute {
+				t.Errorf("expected 15 minutes of go; got %v", goDuration)
+			}
+		})
+	}
+}
+
+func TestHashIsSaltedAndStable(t *testing.T) {
+	t.Parallel()
+
+	mock := &mockWriter{}
+	w := privacy.New(mock, []byte("salt"), privacy.WithMode(privacy.ModeHash))
+	other := privacy.New(&mockWriter{}, []byte("pepper"), privacy.WithMode(privacy.ModeHash))
+
+	if err := w.Write(context.Background(), newSession()); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(context.Background(), newSession()); err != nil {
+		t.Fatal(err)
+	}
+
+	first, second := paths(mock.sessions[0].Repositories[0].Files), paths(mock.sessions[1].Repositories[0].Files)
+	if !slices.Equal(first, second) {
+		t.Errorf("expected the hashes to be stable; got %v and %v", first, second)
+	}
+	for _, p := range first {
+		if p == "api/cmd/main.go" || filepath.Dir(p) != "api" {
+			t.Errorf("expected the path to be hashed within the repository; got %s", p)
+		}
+	}
+
+	salted := paths(other.Redact(newSession()).Repositories[0].Files)
+	if slices.Equal(first, salted) {
+		t.Error("expected different salts to produce different hashes")
+	}
+}
+
+func TestLoadSalt(t *testing.T) {
+	t.Parallel()
+
+	filename := filepath.Join(t.TempDir(), "salt")
+	salt, err := privacy.LoadSalt(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := privacy.LoadSalt(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(salt) == 0 || !slices.Equal(salt, reloaded) {
+		t.Error("expected the salt to be generated once and then reused")
+	}
+
+	// A salt that was truncated shouldn't be used.
+	if err = os.WriteFile(filename, salt[:len(salt)/2], 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = privacy.LoadSalt(filename); err == nil {
+		t.Error("expected a truncated salt to be rejected")
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	t.Parallel()
+
+	if mode, err := privacy.ParseMode(""); err != nil || mode != privacy.ModeKeep {
+		t.Errorf("expected an empty mode to default to keep; got %s, %v", mode, err)
+	}
+	if _, err := privacy.ParseMode("obfuscate"); err == nil {
+		t.Error("expected an unknown mode to be rejected")
+	}
+}
@@ -48,6 +48,16 @@ func (c *Client) Write(ctx context.Context, session pulse.CodingSession) error {
 	return c.redisClient.Set(ctx, session.DateString(), bytes, 0).Err()
 }
 
+// Replace overwrites the session that is stored for the same date,
+// instead of merging with it. It's used to rewrite stored sessions.
+func (c *Client) Replace(ctx context.Context, session pulse.CodingSession) error {
+	bytes, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	return c.redisClient.Set(ctx, session.DateString(), bytes, 0).Err()
+}
+
 func (c *Client) ReadAll(ctx context.Context) (pulse.CodingSessions, error) {
 	var sessions pulse.CodingSessions
 	var keys []string
@@ -9,49 +9,77 @@ import (
 // might open files across any number of repos. The files of
 // the coding session are later grouped by repository.
 type Repository struct {
+	// ID is the fully qualified identity of the repository, e.g.
+	// github.com/viccon/pulse. The name is kept short for reports.
+	ID       string        `json:"id,omitempty"`
 	Name     string        `json:"name"`
 	Files    Files         `json:"files"`
 	Duration time.Duration `json:"duration"`
+	Branches Durations     `json:"branches,omitempty"`
+	// Superproject is set if the repository is a submodule of another repository.
+	Superproject string `json:"superproject,omitempty"`
 }
 
 // merge takes two repositories, merges them, and returns the result.
 func (r Repository) merge(b Repository) Repository {
 	return Repository{
-		Name:     cmp.Or(r.Name, b.Name),
-		Files:    r.Files.merge(b.Files),
-		Duration: r.Duration + b.Duration,
+		ID:           cmp.Or(r.ID, b.ID),
+		Name:         cmp.Or(r.Name, b.Name),
+		Files:        r.Files.merge(b.Files),
+		Duration:     r.Duration + b.Duration,
+		Branches:     r.Branches.merge(b.Branches),
+		Superproject: cmp.Or(r.Superproject, b.Superproject),
 	}
 }
 
 // Repositories represents a list of git repositories.
 type Repositories []Repository
 
-// repositoriesByName takes a slice of repositories and returns a map
-// where the repository name is the key and the repository the value.
-func repositoriesByName(repos Repositories) map[string]Repository {
-	nameRepoMap := make(map[string]Repository)
-	for _, repo := range repos {
-		nameRepoMap[repo.Name] = repo
-	}
-	return nameRepoMap
+// identity returns the key that is used to tell repositories apart. Repositories
+// that were recorded before we started to qualify them only have a name.
+func (r Repository) identity() string {
+	return cmp.Or(r.ID, r.Name)
 }
 
 // merge takes two lists of repositories, merges them, and returns the result.
+// Repositories that were recorded before we started to qualify them are merged
+// into the qualified repository with the same name. If several qualified
+// repositories share the name, we can't tell which one it was, and the
+// repository is kept apart.
 func (r Repositories) merge(b Repositories) Repositories {
-	aNames, bNames := repositoriesByName(r), repositoriesByName(b)
-	allNames := make(map[string]bool)
-	for name := range aNames {
-		allNames[name] = true
+	all := append(append(make(Repositories, 0, len(r)+len(b)), r...), b...)
+	mergedRepositories := make(Repositories, 0, len(all))
+	indexes := make(map[string]int)
+	qualifiedByName := make(map[string][]string)
+	add := func(repo Repository) {
+		if index, ok := indexes[repo.identity()]; ok {
+			mergedRepositories[index] = mergedRepositories[index].merge(repo)
+			return
+		}
+		indexes[repo.identity()] = len(mergedRepositories)
+		mergedRepositories = append(mergedRepositories, repo)
 	}
-	for name := range bNames {
-		allNames[name] = true
+
+	for _, repo := range all {
+		if repo.ID == "" {
+			continue
+		}
+		if _, ok := indexes[repo.ID]; !ok {
+			qualifiedByName[repo.Name] = append(qualifiedByName[repo.Name], repo.ID)
+		}
+		add(repo)
 	}
 
-	mergedRepositories := make([]Repository, 0)
-	for name := range allNames {
-		aRepo := aNames[name]
-		bRepo := bNames[name]
-		mergedRepositories = append(mergedRepositories, aRepo.merge(bRepo))
+	for _, repo := range all {
+		if repo.ID != "" {
+			continue
+		}
+		if ids := qualifiedByName[repo.Name]; len(ids) == 1 {
+			index := indexes[ids[0]]
+			mergedRepositories[index] = mergedRepositories[index].merge(repo)
+			continue
+		}
+		add(repo)
 	}
 	return mergedRepositories
 }
@@ -8,33 +8,73 @@ import (
 )
 
 // writeToRemote will write the session to the remote storage.
-func (s *Server) writeToRemote(session pulse.CodingSession) {
+func (s *Server) writeToRemote(ctx context.Context, session pulse.CodingSession) error {
 	if len(session.Repositories) == 0 {
-		return
-	}
-
-	err := s.sessionWriter.Write(context.Background(), session)
-	if err != nil {
-		s.logger.Errorf("Failed to write the session to the permanent storage: %v", err)
+		return nil
 	}
+	return s.sessionWriter.Write(ctx, session)
 }
 
-func (s *Server) aggregate() {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	buffers := make(pulse.Buffers, 0)
-	values := s.logDB.Aggregate()
-	for _, value := range values {
+// decodeBuffers unmarshals the values of a snapshot into buffers.
+func decodeBuffers(values map[string][]byte) pulse.Buffers {
+	buffers := make(pulse.Buffers, 0, len(values))
+	for key, value := range values {
 		var buf pulse.Buffer
 		err := json.Unmarshal(value, &buf)
 		if err != nil {
 			panic(err)
 		}
+
+		// Buffers that were written before we started to persist the time
+		// they were opened can still be attributed to a day using their key.
+		if buf.OpenedAt.IsZero() {
+			if date, dateErr := pulse.KeyDate(key); dateErr == nil {
+				buf.OpenedAt = date
+			}
+		}
 		buffers = append(buffers, buf)
 	}
-	codingSession := pulse.NewCodingSession(buffers, s.clock.Now())
-	go s.writeToRemote(codingSession)
+	return buffers
+}
+
+// aggregate takes a snapshot of the buffers in the log database and writes
+// them to the remote storage, with one session for each day. The snapshot is
+// only removed from disk once every session has been written. If a write
+// fails, the same snapshot is going to be retried on the next tick.
+func (s *Server) aggregate(ctx context.Context) {
+	// The lock prevents the snapshot from being taken while we're merging a buffer.
+	s.mu.Lock()
+	values, err := s.logDB.Snapshot()
+	s.mu.Unlock()
+	if err != nil {
+		s.logger.Errorf("Failed to take a snapshot of the buffers: %v", err)
+		return
+	}
+
+	for _, session := range pulse.NewCodingSessions(decodeBuffers(values)) {
+		// Skip the days that were written by a previous attempt for this snapshot.
+		// The days are checkpointed in the log database, which means that they're
+		// skipped even if the server was restarted before the snapshot was acknowledged.
+		if s.logDB.Checkpointed(session.DateString()) {
+			continue
+		}
+
+		err = s.writeToRemote(ctx, session)
+		if err != nil {
+			s.logger.Errorf("Failed to write the session to the permanent storage. Retrying on the next tick: %v", err)
+			return
+		}
+		if err = s.logDB.Checkpoint(session.DateString()); err != nil {
+			s.logger.Errorf("Failed to checkpoint the session. Retrying on the next tick: %v", err)
+			return
+		}
+		s.recordAggregated(session)
+	}
+
+	if err = s.logDB.Acknowledge(); err != nil {
+		s.logger.Errorf("Failed to acknowledge the snapshot: %v", err)
+		return
+	}
 }
 
 func (s *Server) runAggregations(ctx context.Context) {
@@ -46,7 +86,7 @@ func (s *Server) runAggregations(ctx context.Context) {
 			case <-ctx.Done():
 				return
 			case <-ticker:
-				s.aggregate()
+				s.aggregate(ctx)
 			}
 		}
 	}()
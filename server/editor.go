@@ -0,0 +1,50 @@
+package server
+
+import (
+	"time"
+
+	"github.com/viccon/pulse"
+)
+
+// The editor policies decide how time is credited when
+// several editor instances are open at the same time.
+const (
+	// PolicyFocus credits the editor that opened a buffer most recently.
+	// The buffers of every other editor are closed when that happens.
+	PolicyFocus = pulse.EditorPolicyFocus
+	// PolicyParallel credits every editor that has an active buffer.
+	PolicyParallel = pulse.EditorPolicyParallel
+)
+
+// editor holds the state of a single editor instance.
+type editor struct {
+	id            string
+	activeBuffer  *pulse.Buffer
+	lastHeartbeat time.Time
+}
+
+// editor returns the state for the editor with the given id. The
+// state is created if this is the first event that we've received
+// from the editor. Should be called with a lock.
+func (s *Server) editor(id string) *editor {
+	e, ok := s.editors[id]
+	if !ok {
+		e = &editor{id: id}
+		s.editors[id] = e
+	}
+	return e
+}
+
+// focusEditor closes the buffers of every other editor if we're only
+// supposed to credit one editor at a time. Should be called with a lock.
+func (s *Server) focusEditor(focused *editor) {
+	if s.cfg.Server.EditorPolicy == PolicyParallel {
+		return
+	}
+
+	for _, e := range s.editors {
+		if e != focused {
+			s.saveBuffer(e, s.clock.Now())
+		}
+	}
+}
@@ -9,7 +9,8 @@ func (s *Server) FocusGained(event pulse.Event, reply *string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.lastHeartbeat = s.clock.Now()
+	e := s.editor(event.EditorID)
+	e.lastHeartbeat = s.clock.Now()
 	s.logger.Debug("Received FocusGained event",
 		"editor_id", event.EditorID,
 		"editor", event.Editor,
@@ -20,7 +21,7 @@ func (s *Server) FocusGained(event pulse.Event, reply *string) {
 		return
 	}
 
-	s.openFile(event)
+	s.openFile(e, event)
 	*reply = "Successfully updated the client being focused"
 }
 
@@ -29,7 +30,8 @@ func (s *Server) OpenFile(event pulse.Event, reply *string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.lastHeartbeat = s.clock.Now()
+	e := s.editor(event.EditorID)
+	e.lastHeartbeat = s.clock.Now()
 	s.logger.Debug("Received OpenFile event",
 		"editor_id", event.EditorID,
 		"editor", event.Editor,
@@ -40,18 +42,18 @@ func (s *Server) OpenFile(event pulse.Event, reply *string) {
 		return
 	}
 
-	s.openFile(event)
+	s.openFile(e, event)
 	*reply = "Successfully updated the current file"
 }
 
 // SendHeartbeat can be called for events such as buffer writes and cursor moves.
 // Its purpose is to notify the server that the current session remains active.
-// The server ends the session if it doesn't receive a heartbeat for 10 minutes.
+// The server ends the session if it doesn't receive a heartbeat before the TTL expires.
 func (s *Server) SendHeartbeat(event pulse.Event, reply *string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.lastHeartbeat = s.clock.Now()
+	s.editor(event.EditorID).lastHeartbeat = s.clock.Now()
 	s.logger.Debug("Received heartbeat",
 		"editor_id", event.EditorID,
 		"editor", event.Editor,
@@ -61,6 +63,7 @@ func (s *Server) SendHeartbeat(event pulse.Event, reply *string) {
 }
 
 // EndSession should be called by the *VimLeave* autocommand.
+// It only ends the session of the editor that sent the event.
 func (s *Server) EndSession(event pulse.Event, reply *string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -70,6 +73,51 @@ func (s *Server) EndSession(event pulse.Event, reply *string) {
 		"editor", event.Editor,
 		"os", event.OS,
 	)
-	s.saveBuffer()
+	if e, ok := s.editors[event.EditorID]; ok {
+		s.saveBuffer(e, s.clock.Now())
+		delete(s.editors, event.EditorID)
+	}
 	*reply = "The session was ended successfully"
 }
+
+// Today returns a coding session for the current day, which is built from local
+// data. It holds the buffers that are currently open, the buffers that haven't
+// been aggregated yet, and the part of the day that has already been written
+// to the remote storage. Days that were aggregated by another machine, or
+// before the server was upgraded, are only partially included.
+func (s *Server) Today(event pulse.Event, reply *pulse.CodingSession) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.logger.Debug("Received Today event",
+		"editor_id", event.EditorID,
+		"editor", event.Editor,
+		"os", event.OS,
+	)
+
+	now := s.clock.Now()
+	prefix := pulse.KeyPrefix(now)
+	buffers := s.scanBuffers(s.logDB.Scan(prefix))
+	for _, e := range s.editors {
+		if e.activeBuffer == nil {
+			continue
+		}
+		buf := *e.activeBuffer
+		buf.Close(now)
+		// Only the part of the buffer that belongs to today is of interest.
+		parts := buf.SplitByDay()
+		buffers = append(buffers, parts[len(parts)-1])
+	}
+	session := pulse.NewCodingSession(buffers, now)
+
+	// The pending snapshot holds buffers that haven't been written to the
+	// remote storage, unless the day was written before the snapshot failed.
+	if !s.logDB.Checkpointed(session.DateString()) {
+		session = session.Merge(pulse.NewCodingSession(s.scanBuffers(s.logDB.ScanSnapshot(prefix)), now))
+	}
+	if s.aggregated.DateString() == session.DateString() {
+		session = session.Merge(s.aggregated)
+	}
+
+	*reply = session
+}
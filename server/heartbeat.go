@@ -1,47 +1,81 @@
 package server
 
 import (
+	"cmp"
 	"context"
 	"strconv"
 	"time"
 )
 
 const (
-	HeartbeatTTL      = time.Minute * 10
-	heartbeatInterval = time.Second * 10
+	DefaultHeartbeatTTL      = time.Minute * 10
+	defaultHeartbeatInterval = time.Second * 10
 )
 
-// CheckHeartbeat is used to check if the session has been inactive for more than
-// ten minutes. If that is the case, the session will be terminated and saved to disk.
+// heartbeatTTL returns the amount of time that the editors active buffer
+// is allowed to go without a heartbeat. Filetypes can override the default,
+// which is useful for files that we tend to read rather than edit.
+func (s *Server) heartbeatTTL(e *editor) time.Duration {
+	if e.activeBuffer != nil {
+		if ttl, ok := s.cfg.Server.FiletypeHeartbeatTTL[e.activeBuffer.Filetype]; ok {
+			return ttl
+		}
+	}
+	return cmp.Or(s.cfg.Server.HeartbeatTTL, DefaultHeartbeatTTL)
+}
+
+// CheckHeartbeat is used to check if any of the editors has been inactive for
+// longer than the heartbeats time to live. If that is the case, the session will
+// be terminated and saved to disk. The buffer is closed at the time of the last
+// heartbeat, plus the optional grace period, so that the time we spent away
+// from the keyboard isn't counted.
 func (s *Server) checkHeartbeat() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.logger.Debug("Checking heartbeat",
-		"last_heartbeat", s.lastHeartbeat,
-		"time_now", s.clock.Now().UnixMilli(),
-	)
+	now := s.clock.Now()
+	for id, e := range s.editors {
+		s.logger.Debug("Checking heartbeat",
+			"editor_id", id,
+			"last_heartbeat", e.lastHeartbeat,
+			"time_now", now.UnixMilli(),
+		)
 
-	if s.activeBuffer == nil {
-		return
-	}
+		ttl := s.heartbeatTTL(e)
+		if !now.After(e.lastHeartbeat.Add(ttl)) {
+			continue
+		}
+
+		// The editor might have been closed without us receiving an EndSession
+		// event. It's going to be added back if we receive another event.
+		delete(s.editors, id)
+		if e.activeBuffer == nil {
+			continue
+		}
+
+		endTime := e.lastHeartbeat.Add(s.cfg.Server.IdleGracePeriod)
+		if endTime.After(now) {
+			endTime = now
+		}
 
-	if s.clock.Now().After(s.lastHeartbeat.Add(HeartbeatTTL)) {
 		s.logger.Info(
 			"Writing the current buffer to disk due to inactivity",
-			"last_heartbeat", strconv.FormatInt(s.lastHeartbeat.UnixMilli(), 10),
-			"current_time", strconv.FormatInt(s.clock.Now().UnixMilli(), 10),
-			"end_time", strconv.FormatInt(s.lastHeartbeat.Add(HeartbeatTTL).UnixMilli(), 10),
+			"editor_id", id,
+			"last_heartbeat", strconv.FormatInt(e.lastHeartbeat.UnixMilli(), 10),
+			"current_time", strconv.FormatInt(now.UnixMilli(), 10),
+			"end_time", strconv.FormatInt(endTime.UnixMilli(), 10),
+			"ttl", ttl,
 		)
-		s.saveBuffer()
+		s.saveBuffer(e, endTime)
 	}
 }
 
-// runHeartbeatChecks runs in a separate goroutine and makes sure
-// that no session is allowed to be idle for more than 10 minutes.
+// runHeartbeatChecks runs in a separate goroutine and makes sure that
+// no session is allowed to be idle for longer than the heartbeat TTL.
 func (s *Server) runHeartbeatChecks(ctx context.Context) {
 	go func() {
-		ticker, stopTicker := s.clock.NewTicker(heartbeatInterval)
+		interval := cmp.Or(s.cfg.Server.HeartbeatInterval, defaultHeartbeatInterval)
+		ticker, stopTicker := s.clock.NewTicker(interval)
 		defer stopTicker()
 		for {
 			select {
@@ -18,7 +18,15 @@ func WithClock(clock clock.Clock) Option {
 // FileReader is a simple abstraction that defines a function
 // for getting metadata from a file within a git repository.
 type FileReader interface {
-	GitFile(path string) (pulse.GitFile, error)
+	ParseFile(absolutePath, filetype string) (pulse.GitFile, error)
+}
+
+// WithFileReader sets the file reader used by the server. It
+// defaults to a git.FileParser that reads from the filesystem.
+func WithFileReader(reader FileReader) Option {
+	return func(a *Server) {
+		a.fileReader = reader
+	}
 }
 
 // WithLog sets the logger used by the server.
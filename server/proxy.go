@@ -30,12 +30,20 @@ func (p *Proxy) OpenFile(event pulse.Event, reply *string) error {
 // SendHeartbeat can be called for events such as buffer writes
 // and cursor moves. Its purpose is to notify the server that
 // the current session remains active. If we don't perform any
-// actions for 10 minutes the server is going to end the session.
+// actions before the heartbeat TTL expires the server is going
+// to end the session.
 func (p *Proxy) SendHeartbeat(event pulse.Event, reply *string) error {
 	p.server.SendHeartbeat(event, reply)
 	return nil
 }
 
+// Today returns what has been worked on today, according to the data
+// that is stored locally on the machine that the server is running on.
+func (p *Proxy) Today(event pulse.Event, reply *pulse.CodingSession) error {
+	p.server.Today(event, reply)
+	return nil
+}
+
 // EndSession should be called when the neovim process ends.
 func (p *Proxy) EndSession(event pulse.Event, reply *string) error {
 	p.server.EndSession(event, reply)
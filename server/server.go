@@ -7,6 +7,9 @@ import (
 	"net"
 	"net/http"
 	"net/rpc"
+	"path"
+	"regexp"
+	"strings"
 	"sync"
 	"time"
 
@@ -14,6 +17,7 @@ import (
 	"github.com/viccon/pulse"
 	"github.com/viccon/pulse/clock"
 	"github.com/viccon/pulse/git"
+	"github.com/viccon/pulse/ignore"
 	"github.com/viccon/pulse/logdb"
 	"github.com/viccon/pulse/logger"
 )
@@ -23,14 +27,40 @@ type SessionWriter interface {
 }
 
 type Server struct {
-	cfg           *pulse.Config
-	clock         clock.Clock
-	logDB         *logdb.LogDB
-	logger        *log.Logger
+	cfg        *pulse.Config
+	clock      clock.Clock
+	logDB      *logdb.LogDB
+	logger     *log.Logger
+	fileReader FileReader
+	// ignoredNames, ignoredIDs, and ignoredPaths hold the patterns of the config.
+	// Patterns without a slash are matched against the name of the repository,
+	// and the ones with a slash against its fully qualified identity.
+	ignoredNames  ignore.Matcher
+	ignoredIDs    ignore.Matcher
+	ignoredPaths  ignore.Matcher
+	aliases       pulse.Aliases
 	mu            sync.Mutex
-	activeBuffer  *pulse.Buffer
-	lastHeartbeat time.Time
+	editors       map[string]*editor
 	sessionWriter SessionWriter
+	segmentPath   string
+	// aggregated holds the most recent day that has been written to the remote storage.
+	aggregated pulse.CodingSession
+}
+
+// newGitParser creates a parser for the files that are opened,
+// which has been configured according to the users preferences.
+func newGitParser(cfg *pulse.Config) git.FileParser {
+	parser := git.New()
+	// The pattern has already been validated when the config was parsed.
+	if cfg.Git.TicketPattern != "" {
+		parser.TicketExp = regexp.MustCompile(cfg.Git.TicketPattern)
+	}
+	parser.RecordSuperproject = cfg.Git.RecordSuperproject
+	parser.Remote = cfg.Git.Remote
+	if cfg.Git.ProjectMarkers != nil {
+		parser.Markers = cfg.Git.ProjectMarkers
+	}
+	return parser
 }
 
 // New creates a new server.
@@ -39,35 +69,92 @@ func New(cfg *pulse.Config, segmentPath string, sessionWriter SessionWriter, opt
 		cfg:           cfg,
 		clock:         clock.New(),
 		logger:        logger.New(),
+		editors:       make(map[string]*editor),
 		sessionWriter: sessionWriter,
+		segmentPath:   segmentPath,
 	}
 
 	for _, opt := range opts {
 		opt(s)
 	}
 
-	s.logDB = logdb.NewDB(segmentPath, cfg.Server.SegmentSizeKB, s.clock)
+	if s.fileReader == nil {
+		s.fileReader = newGitParser(cfg)
+	}
+
+	// The patterns have already been validated when the config was parsed.
+	names, ids := ignore.SplitAnchored(cfg.Ignore.Repositories)
+	s.ignoredNames, _ = ignore.New(names)
+	s.ignoredIDs, _ = ignore.New(ids)
+	s.ignoredPaths, _ = ignore.New(cfg.Ignore.Paths)
+	s.aliases, _ = pulse.NewAliases(cfg.Aliases)
+
+	s.logDB = logdb.NewDB(segmentPath, cfg.Server.SegmentSizeKB, s.clock, logdb.WithMergeFunc(mergeBuffers))
+
+	aggregated, err := readAggregated(path.Join(segmentPath, aggregatedFilename))
+	if err != nil {
+		s.logger.Error("Failed to read the aggregated session", "err", err)
+	}
+	s.aggregated = aggregated
 
 	return s
 }
 
-func (s *Server) openFile(event pulse.Event) {
-	gitFile, gitFileErr := git.ParseFile(event.Path, event.Filetype)
+// cacheHitRate returns the hit rate of the repository cache,
+// if the file reader that the server was configured with has one.
+func (s *Server) cacheHitRate() float64 {
+	if cached, ok := s.fileReader.(interface{ CacheStats() git.CacheStats }); ok {
+		return cached.CacheStats().HitRate()
+	}
+	return 0
+}
+
+// isIgnored reports whether the file matches any of the ignore rules.
+func (s *Server) isIgnored(gitFile pulse.GitFile) bool {
+	if gitFile.Ignored {
+		return true
+	}
+	if s.ignoredNames.Match(gitFile.Repository) ||
+		(gitFile.RepositoryID != "" && s.ignoredIDs.Match(gitFile.RepositoryID)) {
+		return true
+	}
+
+	// The path of the file is prefixed with the name of the repository.
+	_, relativePath, _ := strings.Cut(gitFile.Path, "/")
+	return s.ignoredPaths.Match(relativePath)
+}
+
+func (s *Server) openFile(e *editor, event pulse.Event) {
+	gitFile, gitFileErr := s.fileReader.ParseFile(event.Path, event.Filetype)
 	if gitFileErr != nil {
 		return
 	}
 
+	gitFile = s.aliases.ResolveFile(gitFile)
+
+	// Ignored files are treated like buffers that aren't under source control.
+	if s.isIgnored(gitFile) {
+		s.logger.Debug("The file is ignored", "path", gitFile.Path, "repository", gitFile.Repository)
+		return
+	}
+
 	s.logger.Debug("Successfully parsed the git file",
 		"name", gitFile.Name,
 		"filetype", gitFile.Filetype,
 		"repository", gitFile.Repository,
+		"cache_hit_rate", s.cacheHitRate(),
 	)
 
-	if s.activeBuffer != nil {
-		if s.activeBuffer.Filepath == gitFile.Path && s.activeBuffer.Repository == gitFile.Repository {
+	s.focusEditor(e)
+	if e.activeBuffer != nil {
+		if e.activeBuffer.Filepath == gitFile.Path &&
+			e.activeBuffer.Repository == gitFile.Repository &&
+			e.activeBuffer.RepositoryID == gitFile.RepositoryID &&
+			e.activeBuffer.Branch == gitFile.Branch {
 			s.logger.Debug("This buffer is already considered active",
 				"path", gitFile.Path,
 				"repository", gitFile.Repository,
+				"branch", gitFile.Branch,
 				"editor_id", event.EditorID,
 				"editor", event.Editor,
 				"os", event.OS,
@@ -76,7 +163,7 @@ func (s *Server) openFile(event pulse.Event) {
 		}
 	}
 
-	s.saveBuffer()
+	s.saveBuffer(e, s.clock.Now())
 	buf := pulse.NewBuffer(
 		gitFile.Name,
 		gitFile.Repository,
@@ -84,37 +171,66 @@ func (s *Server) openFile(event pulse.Event) {
 		gitFile.Path,
 		s.clock.Now(),
 	)
-	s.activeBuffer = &buf
+	buf.RepositoryID = gitFile.RepositoryID
+	buf.Branch = gitFile.Branch
+	buf.Ticket = gitFile.Ticket
+	buf.Superproject = gitFile.Superproject
+	buf.Editor = event.Editor
+	buf.OS = event.OS
+	buf.Hostname = event.Hostname
+	e.activeBuffer = &buf
 }
 
-// saveBuffer writes the currently open buffer to disk. Should be called with a lock.
-func (s *Server) saveBuffer() {
-	if s.activeBuffer == nil {
+// saveBuffer closes the editors active buffer at the given
+// time and writes it to disk. Should be called with a lock.
+func (s *Server) saveBuffer(e *editor, closedAt time.Time) {
+	if e.activeBuffer == nil {
 		return
 	}
 
-	s.logger.Debug("Writing the buffer")
-	buf := s.activeBuffer
-	buf.Close(s.clock.Now())
-	key := buf.Key()
+	s.logger.Debug("Writing the buffer", "editor_id", e.id)
+	if closedAt.Before(e.activeBuffer.OpenedAt) {
+		closedAt = e.activeBuffer.OpenedAt
+	}
+	e.activeBuffer.Close(closedAt)
 
-	// Merge the duration with the most recent entry for this day.
-	if bytes, hasMostRecentEntry := s.logDB.Get(key); hasMostRecentEntry {
-		s.logger.Debug("Merging with the most recent entry for this buffer")
-		var b pulse.Buffer
-		err := json.Unmarshal(bytes, &b)
+	// A buffer that was kept open past midnight is split so that each day is
+	// credited with its own share of the time. The parts are written as one
+	// batch, which means that a crash can't leave only some of the days.
+	var batch logdb.Batch
+	for _, buf := range e.activeBuffer.SplitByDay() {
+		bytes, err := json.Marshal(buf)
 		if err != nil {
 			panic(err)
 		}
-		buf.Duration += b.Duration
+		// The duration is merged with the most recent entry for this day when it's read.
+		batch.Merge(buf.Key(), bytes)
 	}
-
-	bytes, err := json.Marshal(buf)
-	if err != nil {
+	if err := s.logDB.WriteBatch(&batch); err != nil {
 		panic(err)
 	}
-	s.logDB.MustSet(key, bytes)
-	s.activeBuffer = nil
+	e.activeBuffer = nil
+}
+
+// mergeBuffers folds the buffers that have been written for the same key. The
+// most recent buffer is kept, with the durations of the previous ones added to it.
+func mergeBuffers(value []byte, operands [][]byte) ([]byte, error) {
+	var merged pulse.Buffer
+	if value != nil {
+		if err := json.Unmarshal(value, &merged); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, operand := range operands {
+		var buf pulse.Buffer
+		if err := json.Unmarshal(operand, &buf); err != nil {
+			return nil, err
+		}
+		buf.Duration += merged.Duration
+		merged = buf
+	}
+	return json.Marshal(merged)
 }
 
 // RunBackgroundJobs starts the heartbeat, aggregation, and segmentation jobs.
@@ -152,7 +268,11 @@ func (s *Server) StartServer(ctx context.Context, port string) error {
 	// Blocks until the context is cancelled.
 	<-ctx.Done()
 	s.logger.Info("Shutting down")
-	s.saveBuffer()
+	s.mu.Lock()
+	for _, e := range s.editors {
+		s.saveBuffer(e, s.clock.Now())
+	}
+	s.mu.Unlock()
 
 	shutdownContext, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
@@ -2,10 +2,8 @@ package server_test
 
 import (
 	"context"
+	"errors"
 	"io"
-	"os"
-	"path/filepath"
-	"runtime"
 	"sync"
 	"testing"
 	"time"
@@ -13,11 +11,13 @@ import (
 	"github.com/charmbracelet/log"
 	"github.com/viccon/pulse"
 	"github.com/viccon/pulse/clock"
+	"github.com/viccon/pulse/git"
 	"github.com/viccon/pulse/server"
 )
 
 type mockStorage struct {
 	sync.Mutex
+	err      error
 	sessions []pulse.CodingSession
 }
 
@@ -30,61 +30,85 @@ func newMockStorage() *mockStorage {
 func (m *mockStorage) Write(_ context.Context, session pulse.CodingSession) error {
 	m.Lock()
 	defer m.Unlock()
+	if m.err != nil {
+		return m.err
+	}
 	m.sessions = append(m.sessions, session)
 	return nil
 }
 
+func (m *mockStorage) SetErr(err error) {
+	m.Lock()
+	defer m.Unlock()
+	m.err = err
+}
+
 func (m *mockStorage) GetSessions() []pulse.CodingSession {
 	m.Lock()
 	defer m.Unlock()
 	return m.sessions
 }
 
-func absolutePath(t *testing.T, relativePath string) string {
-	t.Helper()
-	_, filename, _, ok := runtime.Caller(0)
-	if !ok {
-		t.Fatal("Could not get current file path")
-	}
-	return filepath.Join(filepath.Dir(filename), relativePath)
+const (
+	mainFile = "/Users/conner/code/sturdyc/cmd/main.go"
+	fooFile  = "/Users/conner/code/sturdyc/pkg/foo/foo.go"
+)
+
+// newFileReader declares the repositories that the tests open files in.
+func newFileReader() server.FileReader {
+	reader := git.NewMemoryReader()
+	reader.AddRepository("/Users/conner/code/sturdyc", "git@github.com:viccon/sturdyc.git", "main")
+	reader.WriteFile(mainFile, "package main\n")
+	reader.WriteFile(fooFile, "package foo\n")
+
+	parser := git.New()
+	parser.Reader = reader
+	return parser
 }
 
-func TestServerMergesFiles(t *testing.T) {
-	t.Parallel()
+// noon is 12:00 Sunday June 16 2024.
+var noon = time.Date(2024, time.June, 16, 12, 0, 0, 0, time.Local)
 
-	// You can't commit a .git directory. Therefore, we have to rename it to .git in the test runner.
-	err := os.Rename(absolutePath(t, "./testdata/sturdyc/git"), absolutePath(t, "./testdata/sturdyc/.git"))
-	if err != nil {
-		t.Fatal("Failed to set up .git directory for testing:", err)
-	}
-	defer func() {
-		restoreErr := os.Rename(absolutePath(t, "./testdata/sturdyc/.git"), absolutePath(t, "./testdata/sturdyc/git"))
-		if restoreErr != nil {
-			t.Fatal("Failed to store the .git directory:", restoreErr)
-		}
-	}()
+// newTestServer creates a server with a mock clock and storage, and starts its
+// background jobs. The config can be adjusted before the server is created, and
+// the options are applied after the ones that every test uses.
+func newTestServer(t *testing.T, now time.Time, configure func(*pulse.Config), opts ...server.Option) (*server.Server, *clock.MockClock, *mockStorage) {
+	t.Helper()
 
-	mockClock := clock.NewMock(time.Now())
+	mockClock := clock.NewMock(now)
 	mockStorage := newMockStorage()
 	var cfg pulse.Config
 	cfg.Server.Name = "TestApp"
-	cfg.Server.AggregationInterval = 10 * time.Minute
+	cfg.Server.AggregationInterval = 30 * time.Minute
 	cfg.Server.SegmentationInterval = 5 * time.Minute
 	cfg.Server.SegmentSizeKB = 10
+	if configure != nil {
+		configure(&cfg)
+	}
 
-	reply := ""
-	s := server.New(&cfg, t.TempDir(), mockStorage,
+	opts = append([]server.Option{
 		server.WithLog(log.New(io.Discard)),
 		server.WithClock(mockClock),
-	)
+		server.WithFileReader(newFileReader()),
+	}, opts...)
+	s := server.New(&cfg, t.TempDir(), mockStorage, opts...)
 
 	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	go func() {
-		s.RunBackgroundJobs(ctx, cfg.Server.SegmentationInterval)
-	}()
+	t.Cleanup(cancel)
+	s.RunBackgroundJobs(ctx, cfg.Server.SegmentationInterval)
 	time.Sleep(100 * time.Millisecond)
 
+	return s, mockClock, mockStorage
+}
+
+func TestServerMergesFiles(t *testing.T) {
+	t.Parallel()
+
+	s, mockClock, mockStorage := newTestServer(t, time.Now(), func(cfg *pulse.Config) {
+		cfg.Server.AggregationInterval = 10 * time.Minute
+	})
+	reply := ""
+
 	// Open an initial VIM window.
 	s.FocusGained(pulse.Event{
 		EditorID: "123",
@@ -107,7 +131,7 @@ func TestServerMergesFiles(t *testing.T) {
 
 	s.OpenFile(pulse.Event{
 		EditorID: "123",
-		Path:     absolutePath(t, "/testdata/sturdyc/cmd/main.go"),
+		Path:     mainFile,
 		Editor:   "nvim",
 		OS:       "Linux",
 	}, &reply)
@@ -117,7 +141,7 @@ func TestServerMergesFiles(t *testing.T) {
 	// Open a second file.
 	s.OpenFile(pulse.Event{
 		EditorID: "123",
-		Path:     absolutePath(t, "/testdata/sturdyc/pkg/foo/foo.go"),
+		Path:     fooFile,
 		Editor:   "nvim",
 		OS:       "Linux",
 	}, &reply)
@@ -127,7 +151,7 @@ func TestServerMergesFiles(t *testing.T) {
 	// Open the first file again.
 	s.OpenFile(pulse.Event{
 		EditorID: "123",
-		Path:     absolutePath(t, "/testdata/sturdyc/cmd/main.go"),
+		Path:     mainFile,
 		Editor:   "nvim",
 		OS:       "Linux",
 	}, &reply)
@@ -136,7 +160,7 @@ func TestServerMergesFiles(t *testing.T) {
 
 	s.EndSession(pulse.Event{
 		EditorID: "123",
-		Path:     absolutePath(t, "/testdata/sturdyc/cmd/main.go"),
+		Path:     mainFile,
 		Editor:   "nvim",
 		OS:       "Linux",
 	}, &reply)
@@ -159,3 +183,430 @@ func TestServerMergesFiles(t *testing.T) {
 		t.Errorf("expected the repositories files to be 2; got %d", len(storedSessions[0].Repositories[0].Files))
 	}
 }
+
+func TestServerRetriesFailedWrites(t *testing.T) {
+	t.Parallel()
+
+	s, mockClock, mockStorage := newTestServer(t, time.Now(), func(cfg *pulse.Config) {
+		cfg.Server.AggregationInterval = 10 * time.Minute
+	})
+	mockStorage.SetErr(errors.New("the remote storage is unavailable"))
+	reply := ""
+
+	s.OpenFile(pulse.Event{
+		EditorID: "123",
+		Path:     mainFile,
+		Editor:   "nvim",
+		OS:       "Linux",
+	}, &reply)
+	mockClock.Add(100 * time.Millisecond)
+	s.EndSession(pulse.Event{EditorID: "123", Editor: "nvim", OS: "Linux"}, &reply)
+
+	// The first aggregation fails, which means that the buffers should remain on disk.
+	mockClock.Add(10 * time.Minute)
+	time.Sleep(200 * time.Millisecond)
+	if len(mockStorage.GetSessions()) != 0 {
+		t.Fatalf("expected no sessions to be stored; got %d", len(mockStorage.GetSessions()))
+	}
+
+	// Write another buffer while the snapshot is pending.
+	s.OpenFile(pulse.Event{
+		EditorID: "123",
+		Path:     mainFile,
+		Editor:   "nvim",
+		OS:       "Linux",
+	}, &reply)
+	mockClock.Add(50 * time.Millisecond)
+	s.EndSession(pulse.Event{EditorID: "123", Editor: "nvim", OS: "Linux"}, &reply)
+
+	// The next tick should retry the pending snapshot, and the one after that
+	// should pick up the buffer that was written while the write was failing.
+	mockStorage.SetErr(nil)
+	mockClock.Add(10 * time.Minute)
+	time.Sleep(200 * time.Millisecond)
+	mockClock.Add(10 * time.Minute)
+	time.Sleep(200 * time.Millisecond)
+
+	storedSessions := mockStorage.GetSessions()
+	if len(storedSessions) != 2 {
+		t.Fatalf("expected sessions %d; got %d", 2, len(storedSessions))
+	}
+	if storedSessions[0].Duration != 100*time.Millisecond {
+		t.Errorf("expected the first session to be 100 ms; got %v", storedSessions[0].Duration)
+	}
+	if storedSessions[1].Duration != 50*time.Millisecond {
+		t.Errorf("expected the second session to be 50 ms; got %v", storedSessions[1].Duration)
+	}
+}
+
+func TestServerCreditsBuffersToTheDayTheyWereOpened(t *testing.T) {
+	t.Parallel()
+
+	// 23:40 Sunday June 16 2024
+	s, mockClock, mockStorage := newTestServer(t, time.Date(2024, time.June, 16, 23, 40, 0, 0, time.Local), nil)
+	reply := ""
+
+	s.OpenFile(pulse.Event{
+		EditorID: "123",
+		Path:     mainFile,
+		Editor:   "nvim",
+		OS:       "Linux",
+	}, &reply)
+	mockClock.Add(5 * time.Minute)
+	s.EndSession(pulse.Event{EditorID: "123", Editor: "nvim", OS: "Linux"}, &reply)
+
+	// Open a buffer after midnight, before the aggregation runs at 00:10.
+	mockClock.Add(20 * time.Minute)
+	s.OpenFile(pulse.Event{
+		EditorID: "123",
+		Path:     fooFile,
+		Editor:   "nvim",
+		OS:       "Linux",
+	}, &reply)
+	mockClock.Add(2 * time.Minute)
+	s.EndSession(pulse.Event{EditorID: "123", Editor: "nvim", OS: "Linux"}, &reply)
+
+	mockClock.Add(3 * time.Minute)
+	time.Sleep(200 * time.Millisecond)
+
+	storedSessions := mockStorage.GetSessions()
+	if len(storedSessions) != 2 {
+		t.Fatalf("expected sessions %d; got %d", 2, len(storedSessions))
+	}
+	if storedSessions[0].DateString() != "2024-06-16" || storedSessions[0].Duration != 5*time.Minute {
+		t.Errorf("expected 5 minutes on 2024-06-16; got %v on %s", storedSessions[0].Duration, storedSessions[0].DateString())
+	}
+	if storedSessions[1].DateString() != "2024-06-17" || storedSessions[1].Duration != 2*time.Minute {
+		t.Errorf("expected 2 minutes on 2024-06-17; got %v on %s", storedSessions[1].Duration, storedSessions[1].DateString())
+	}
+}
+
+func TestServerSplitsBuffersAtMidnight(t *testing.T) {
+	t.Parallel()
+
+	// 23:30 Sunday June 16 2024
+	s, mockClock, mockStorage := newTestServer(t, time.Date(2024, time.June, 16, 23, 30, 0, 0, time.Local), func(cfg *pulse.Config) {
+		cfg.Server.AggregationInterval = 3 * time.Hour
+	})
+	reply := ""
+
+	event := pulse.Event{
+		EditorID: "123",
+		Path:     mainFile,
+		Editor:   "nvim",
+		OS:       "Linux",
+	}
+	s.OpenFile(event, &reply)
+
+	// Keep the buffer open until 01:30 with a heartbeat every five minutes.
+	for i := 0; i < 24; i++ {
+		mockClock.Add(5 * time.Minute)
+		s.SendHeartbeat(event, &reply)
+	}
+	s.EndSession(event, &reply)
+
+	mockClock.Add(time.Hour)
+	time.Sleep(200 * time.Millisecond)
+
+	storedSessions := mockStorage.GetSessions()
+	if len(storedSessions) != 2 {
+		t.Fatalf("expected sessions %d; got %d", 2, len(storedSessions))
+	}
+	if storedSessions[0].DateString() != "2024-06-16" || storedSessions[0].Duration != 30*time.Minute {
+		t.Errorf("expected 30 minutes on 2024-06-16; got %v on %s", storedSessions[0].Duration, storedSessions[0].DateString())
+	}
+	if storedSessions[1].DateString() != "2024-06-17" || storedSessions[1].Duration != 90*time.Minute {
+		t.Errorf("expected 90 minutes on 2024-06-17; got %v on %s", storedSessions[1].Duration, storedSessions[1].DateString())
+	}
+}
+
+func TestServerDoesNotCountIdleTime(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name            string
+		gracePeriod     time.Duration
+		expectedSession time.Duration
+	}{
+		{"without grace period", 0, time.Minute},
+		{"with grace period", 30 * time.Second, time.Minute + 30*time.Second},
+		{"with grace period exceeding the idle time", time.Hour, 12 * time.Minute},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s, mockClock, mockStorage := newTestServer(t, noon, func(cfg *pulse.Config) {
+				cfg.Server.IdleGracePeriod = tc.gracePeriod
+			})
+			reply := ""
+
+			event := pulse.Event{
+				EditorID: "123",
+				Path:     mainFile,
+				Editor:   "nvim",
+				OS:       "Linux",
+			}
+			s.OpenFile(event, &reply)
+			mockClock.Add(time.Minute)
+			s.SendHeartbeat(event, &reply)
+
+			// Walk away from the keyboard. The heartbeat check runs
+			// once the clock has passed the heartbeats time to live.
+			mockClock.Add(server.DefaultHeartbeatTTL + time.Minute)
+			time.Sleep(100 * time.Millisecond)
+
+			mockClock.Add(30*time.Minute - server.DefaultHeartbeatTTL - 2*time.Minute)
+			time.Sleep(200 * time.Millisecond)
+
+			storedSessions := mockStorage.GetSessions()
+			if len(storedSessions) != 1 {
+				t.Fatalf("expected sessions %d; got %d", 1, len(storedSessions))
+			}
+			if storedSessions[0].Duration != tc.expectedSession {
+				t.Errorf("expected the session to be %v; got %v", tc.expectedSession, storedSessions[0].Duration)
+			}
+		})
+	}
+}
+
+func TestServerUsesFiletypeHeartbeatTTL(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		filetype        string
+		expectedSession time.Duration
+	}{
+		{"go", time.Minute},
+		{"markdown", 6 * time.Minute},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.filetype, func(t *testing.T) {
+			t.Parallel()
+
+			s, mockClock, mockStorage := newTestServer(t, noon, func(cfg *pulse.Config) {
+				cfg.Server.HeartbeatTTL = 2 * time.Minute
+				cfg.Server.HeartbeatInterval = time.Minute
+				cfg.Server.FiletypeHeartbeatTTL = map[string]time.Duration{"markdown": 20 * time.Minute}
+			})
+			reply := ""
+
+			event := pulse.Event{
+				EditorID: "123",
+				Path:     mainFile,
+				Filetype: tc.filetype,
+				Editor:   "nvim",
+				OS:       "Linux",
+			}
+			s.OpenFile(event, &reply)
+			mockClock.Add(time.Minute)
+			s.SendHeartbeat(event, &reply)
+
+			// Read the file for five minutes without moving the cursor.
+			for i := 0; i < 5; i++ {
+				mockClock.Add(time.Minute)
+				time.Sleep(20 * time.Millisecond)
+			}
+			s.EndSession(event, &reply)
+
+			mockClock.Add(30 * time.Minute)
+			time.Sleep(200 * time.Millisecond)
+
+			storedSessions := mockStorage.GetSessions()
+			if len(storedSessions) != 1 {
+				t.Fatalf("expected sessions %d; got %d", 1, len(storedSessions))
+			}
+			if storedSessions[0].Duration != tc.expectedSession {
+				t.Errorf("expected the session to be %v; got %v", tc.expectedSession, storedSessions[0].Duration)
+			}
+		})
+	}
+}
+
+func TestServerEditorPolicies(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		policy           string
+		expectedDuration time.Duration
+		expectedMain     time.Duration
+		expectedFoo      time.Duration
+	}{
+		// The first editor is credited until the second one opens a buffer, and then
+		// again once it gains focus. The second editor is credited in between.
+		{server.PolicyFocus, 5 * time.Minute, 3 * time.Minute, 2 * time.Minute},
+		// Both editors are credited for as long as they have a buffer open.
+		{server.PolicyParallel, 8 * time.Minute, 5 * time.Minute, 3 * time.Minute},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.policy, func(t *testing.T) {
+			t.Parallel()
+
+			s, mockClock, mockStorage := newTestServer(t, noon, func(cfg *pulse.Config) {
+				cfg.Server.EditorPolicy = tc.policy
+			})
+			reply := ""
+
+			first := pulse.Event{
+				EditorID: "first",
+				Path:     mainFile,
+				Editor:   "nvim",
+				OS:       "Linux",
+			}
+			second := pulse.Event{
+				EditorID: "second",
+				Path:     fooFile,
+				Editor:   "nvim",
+				OS:       "Linux",
+			}
+
+			s.OpenFile(first, &reply)
+			mockClock.Add(time.Minute)
+			s.OpenFile(second, &reply)
+			mockClock.Add(2 * time.Minute)
+			s.FocusGained(first, &reply)
+			mockClock.Add(time.Minute)
+
+			// Ending the second editors session shouldn't affect the first one.
+			s.EndSession(second, &reply)
+			mockClock.Add(time.Minute)
+			s.EndSession(first, &reply)
+
+			mockClock.Add(25 * time.Minute)
+			time.Sleep(200 * time.Millisecond)
+
+			storedSessions := mockStorage.GetSessions()
+			if len(storedSessions) != 1 {
+				t.Fatalf("expected sessions %d; got %d", 1, len(storedSessions))
+			}
+			if storedSessions[0].Duration != tc.expectedDuration {
+				t.Errorf("expected the session to be %v; got %v", tc.expectedDuration, storedSessions[0].Duration)
+			}
+			for _, file := range storedSessions[0].Repositories[0].Files {
+				expected := tc.expectedFoo
+				if file.Name == "main.go" {
+					expected = tc.expectedMain
+				}
+				if file.Duration != expected {
+					t.Errorf("expected %s to be %v; got %v", file.Name, expected, file.Duration)
+				}
+			}
+		})
+	}
+}
+
+func TestServerIgnoresFiles(t *testing.T) {
+	t.Parallel()
+
+	reader := git.NewMemoryReader()
+	reader.AddRepository("/Users/conner/code/sturdyc", "git@github.com:viccon/sturdyc.git", "main")
+	reader.WriteFile(mainFile, "package main\n")
+	reader.WriteFile("/Users/conner/code/sturdyc/vendor/foo/foo.go", "package foo\n")
+	reader.WriteFile("/Users/conner/code/sturdyc/.env", "SECRET=1\n")
+	reader.WriteFile("/Users/conner/code/sturdyc/.pulseignore", "# Secrets\n*.env\n")
+	reader.AddRepository("/Users/conner/code/journal", "git@github.com:viccon/journal.git", "main")
+	reader.WriteFile("/Users/conner/code/journal/2024-06-16.md", "")
+	reader.AddRepository("/Users/conner/code/secret", "git@github.com:acme/secret.git", "main")
+	reader.WriteFile("/Users/conner/code/secret/main.go", "package main\n")
+	// The owner shares its name with the ignored repository, which shouldn't matter.
+	reader.AddRepository("/Users/conner/code/api", "git@github.com:journal/api.git", "main")
+	reader.WriteFile("/Users/conner/code/api/main.go", "package main\n")
+	parser := git.New()
+	parser.Reader = reader
+
+	s, mockClock, mockStorage := newTestServer(t, noon, func(cfg *pulse.Config) {
+		cfg.Server.AggregationInterval = time.Hour
+		cfg.Ignore.Repositories = []string{"journal", "github.com/acme/*"}
+		cfg.Ignore.Paths = []string{"vendor/**"}
+	}, server.WithFileReader(parser))
+	reply := ""
+
+	paths := []string{
+		mainFile,
+		"/Users/conner/code/sturdyc/vendor/foo/foo.go",
+		"/Users/conner/code/sturdyc/.env",
+		"/Users/conner/code/journal/2024-06-16.md",
+		"/Users/conner/code/secret/main.go",
+		"/Users/conner/code/api/main.go",
+	}
+	for _, path := range paths {
+		s.OpenFile(pulse.Event{EditorID: "123", Path: path, Editor: "nvim", OS: "Linux"}, &reply)
+		mockClock.Add(5 * time.Minute)
+		s.EndSession(pulse.Event{EditorID: "123", Editor: "nvim", OS: "Linux"}, &reply)
+	}
+
+	mockClock.Add(time.Hour)
+	time.Sleep(200 * time.Millisecond)
+
+	storedSessions := mockStorage.GetSessions()
+	if len(storedSessions) != 1 {
+		t.Fatalf("expected sessions %d; got %d", 1, len(storedSessions))
+	}
+	if storedSessions[0].Duration != 10*time.Minute {
+		t.Errorf("expected only the two main files to be tracked for 10 minutes; got %v", storedSessions[0].Duration)
+	}
+	for _, repo := range storedSessions[0].Repositories {
+		if (repo.ID != "github.com/viccon/sturdyc" && repo.ID != "github.com/journal/api") || len(repo.Files) != 1 {
+			t.Errorf("expected a single tracked file in sturdyc and api; got %+v", repo)
+		}
+	}
+}
+
+func TestServerToday(t *testing.T) {
+	t.Parallel()
+
+	s, mockClock, _ := newTestServer(t, noon, func(cfg *pulse.Config) {
+		cfg.Server.AggregationInterval = time.Hour
+	})
+	reply := ""
+
+	s.OpenFile(pulse.Event{EditorID: "123", Path: mainFile, Editor: "nvim", OS: "Linux"}, &reply)
+	mockClock.Add(10 * time.Minute)
+	s.OpenFile(pulse.Event{EditorID: "123", Path: fooFile, Editor: "nvim", OS: "Linux"}, &reply)
+	mockClock.Add(5 * time.Minute)
+
+	// The buffer that is still open should be included.
+	var session pulse.CodingSession
+	s.Today(pulse.Event{EditorID: "123", Editor: "nvim", OS: "Linux"}, &session)
+	if session.DateString() != "2024-06-16" {
+		t.Errorf("expected the session to be dated 2024-06-16; got %s", session.DateString())
+	}
+	if session.Duration != 15*time.Minute {
+		t.Errorf("expected 15 minutes; got %v", session.Duration)
+	}
+	if len(session.Repositories) != 1 || len(session.Repositories[0].Files) != 2 {
+		t.Errorf("expected two files in a single repository; got %+v", session.Repositories)
+	}
+}
+
+func TestServerTodayIncludesAggregatedBuffers(t *testing.T) {
+	t.Parallel()
+
+	s, mockClock, mockStorage := newTestServer(t, noon, func(cfg *pulse.Config) {
+		cfg.Server.HeartbeatTTL = time.Hour
+	})
+	reply := ""
+
+	s.OpenFile(pulse.Event{EditorID: "123", Path: mainFile, Editor: "nvim", OS: "Linux"}, &reply)
+	mockClock.Add(10 * time.Minute)
+	s.OpenFile(pulse.Event{EditorID: "123", Path: fooFile, Editor: "nvim", OS: "Linux"}, &reply)
+	mockClock.Add(25 * time.Minute)
+	time.Sleep(200 * time.Millisecond)
+
+	if len(mockStorage.GetSessions()) != 1 {
+		t.Fatalf("expected the first buffer to be aggregated; got %d sessions", len(mockStorage.GetSessions()))
+	}
+
+	// The buffer that has been written to the remote storage should still be included.
+	var session pulse.CodingSession
+	s.Today(pulse.Event{EditorID: "123", Editor: "nvim", OS: "Linux"}, &session)
+	if session.Duration != 35*time.Minute {
+		t.Errorf("expected 35 minutes; got %v", session.Duration)
+	}
+	if len(session.Repositories) != 1 || len(session.Repositories[0].Files) != 2 {
+		t.Errorf("expected two files in a single repository; got %+v", session.Repositories)
+	}
+}
@@ -1,3 +0,0 @@
-#!/bin/bash
-
-echo "Installing dotfiles..."
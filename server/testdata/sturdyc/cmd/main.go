@@ -1,7 +0,0 @@
-package main
-
-import "log"
-
-func main() {
-	log.Println("Hello, World!")
-}
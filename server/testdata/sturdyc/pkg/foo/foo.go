@@ -1,5 +0,0 @@
-package foo
-
-func Bar() string {
-	return "bar"
-}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+
+	"github.com/viccon/pulse"
+	"github.com/viccon/pulse/fsutil"
+	"github.com/viccon/pulse/logdb"
+)
+
+// aggregatedFilename is the name of the file, within the segment directory,
+// that holds the most recent day which has been written to the remote storage.
+// The buffers are removed from the log database once they've been aggregated,
+// and the file allows us to report the entire day from local data.
+const aggregatedFilename = "aggregated.json"
+
+// readAggregated reads the most recent day that has been written to the remote
+// storage. The session is empty if nothing has been aggregated yet.
+func readAggregated(filePath string) (pulse.CodingSession, error) {
+	var session pulse.CodingSession
+	bytes, err := os.ReadFile(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return session, nil
+	}
+	if err != nil {
+		return session, err
+	}
+	err = json.Unmarshal(bytes, &session)
+	return session, err
+}
+
+// recordAggregated merges a session that has been written to the remote storage
+// into the most recent day. Sessions for days before that are of no interest.
+func (s *Server) recordAggregated(session pulse.CodingSession) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case session.DateString() == s.aggregated.DateString():
+		s.aggregated = s.aggregated.Merge(session)
+	case session.Date.After(s.aggregated.Date):
+		s.aggregated = session
+	default:
+		return
+	}
+
+	bytes, err := json.Marshal(s.aggregated)
+	if err != nil {
+		s.logger.Error("Failed to encode the aggregated session", "err", err)
+		return
+	}
+
+	err = fsutil.WriteFileAtomic(path.Join(s.segmentPath, aggregatedFilename), bytes, 0o600)
+	if err != nil {
+		s.logger.Error("Failed to write the aggregated session", "err", err)
+	}
+}
+
+// scanBuffers decodes the buffers of the iterator. Buffers
+// that can't be decoded are reported and left out.
+func (s *Server) scanBuffers(it *logdb.Iterator) pulse.Buffers {
+	buffers := make(pulse.Buffers, 0)
+	for it.Next() {
+		var buf pulse.Buffer
+		if err := json.Unmarshal(it.Value(), &buf); err != nil {
+			s.logger.Error("Failed to decode the buffer", "key", it.Key(), "err", err)
+			continue
+		}
+		buffers = append(buffers, buf)
+	}
+	return buffers
+}
@@ -9,9 +9,13 @@ import (
 // CodingSession represents a coding session that has been aggregated
 // for a given time period (day, week, month, year).
 type CodingSession struct {
-	Date         time.Time     `json:"date"`
-	Duration     time.Duration `json:"duration"`
-	Repositories Repositories  `json:"repositories"`
+	Date             time.Time     `json:"date"`
+	Duration         time.Duration `json:"duration"`
+	Repositories     Repositories  `json:"repositories"`
+	Tickets          Durations     `json:"tickets,omitempty"`
+	Editors          Durations     `json:"editors,omitempty"`
+	Machines         Durations     `json:"machines,omitempty"`
+	OperatingSystems Durations     `json:"operating_systems,omitempty"`
 }
 
 // TruncateDay truncates the time to the start of the day.
@@ -20,12 +24,15 @@ func TruncateDay(t time.Time) time.Time {
 }
 
 func NewCodingSession(buffers Buffers, now time.Time) CodingSession {
+	tickets, editors, machines, operatingSystems := make(Durations), make(Durations), make(Durations), make(Durations)
 	repos := make(map[string]Repository)
 	for _, buf := range buffers {
-		repo, ok := repos[buf.Repository]
+		id := cmp.Or(buf.RepositoryID, buf.Repository)
+		repo, ok := repos[id]
 		if !ok {
-			repo = Repository{Name: buf.Repository, Files: make(Files, 0)}
+			repo = Repository{ID: buf.RepositoryID, Name: buf.Repository, Files: make(Files, 0), Branches: make(Durations)}
 		}
+		repo.Superproject = cmp.Or(repo.Superproject, buf.Superproject)
 
 		file := File{
 			Name:     buf.Filename,
@@ -34,8 +41,15 @@ func NewCodingSession(buffers Buffers, now time.Time) CodingSession {
 			Duration: buf.Duration,
 		}
 		repo.Duration += file.Duration
-		repo.Files = append(repo.Files, file)
-		repos[buf.Repository] = repo
+		repo.Branches.add(buf.Branch, file.Duration)
+		// The same file could have been opened by several editors.
+		repo.Files = repo.Files.merge(Files{file})
+		repos[id] = repo
+
+		tickets.add(buf.Ticket, buf.Duration)
+		editors.add(buf.Editor, buf.Duration)
+		machines.add(buf.Hostname, buf.Duration)
+		operatingSystems.add(buf.OS, buf.Duration)
 	}
 
 	var totalDuration time.Duration
@@ -46,19 +60,46 @@ func NewCodingSession(buffers Buffers, now time.Time) CodingSession {
 	}
 
 	session := CodingSession{
-		Date:         TruncateDay(now),
-		Duration:     totalDuration,
-		Repositories: repositories,
+		Date:             TruncateDay(now),
+		Duration:         totalDuration,
+		Repositories:     repositories,
+		Tickets:          tickets,
+		Editors:          editors,
+		Machines:         machines,
+		OperatingSystems: operatingSystems,
 	}
 	return session
 }
 
+// NewCodingSessions groups the buffers by the day they were opened,
+// and creates a coding session for each day. This ensures that time
+// is credited to the correct day, even if the buffers are aggregated
+// long after they were written.
+func NewCodingSessions(buffers Buffers) CodingSessions {
+	buffersByDay := make(map[time.Time]Buffers)
+	for _, buf := range buffers {
+		day := TruncateDay(buf.OpenedAt.Local())
+		buffersByDay[day] = append(buffersByDay[day], buf)
+	}
+
+	sessions := make(CodingSessions, 0, len(buffersByDay))
+	for day, dayBuffers := range buffersByDay {
+		sessions = append(sessions, NewCodingSession(dayBuffers, day))
+	}
+	sort.Sort(sessions)
+	return sessions
+}
+
 // Merge takes two coding sessions, merges them, and returns the result.
 func (c CodingSession) Merge(other CodingSession) CodingSession {
 	mergedSession := CodingSession{
-		Date:         cmp.Or(c.Date, other.Date),
-		Duration:     c.Duration + other.Duration,
-		Repositories: c.Repositories.merge(other.Repositories),
+		Date:             cmp.Or(c.Date, other.Date),
+		Duration:         c.Duration + other.Duration,
+		Repositories:     c.Repositories.merge(other.Repositories),
+		Tickets:          c.Tickets.merge(other.Tickets),
+		Editors:          c.Editors.merge(other.Editors),
+		Machines:         c.Machines.merge(other.Machines),
+		OperatingSystems: c.OperatingSystems.merge(other.OperatingSystems),
 	}
 
 	return mergedSession
@@ -98,6 +139,23 @@ func merge(sessions CodingSessions, truncate func(time.Time) time.Time) CodingSe
 	return values
 }
 
+// Tickets returns the amount of time that was spent on each ticket
+// between the from and to dates. Both of the dates are inclusive.
+func (s CodingSessions) Tickets(from, to time.Time) Durations {
+	from, to = TruncateDay(from), TruncateDay(to)
+	tickets := make(Durations)
+	for _, session := range s {
+		day := TruncateDay(session.Date)
+		if day.Before(from) || day.After(to) {
+			continue
+		}
+		for ticket, duration := range session.Tickets {
+			tickets.add(ticket, duration)
+		}
+	}
+	return tickets
+}
+
 // MergeByDay merges sessions that occurred the same day.
 func (s CodingSessions) MergeByDay() CodingSessions {
 	return merge(s, TruncateDay)
@@ -159,3 +159,162 @@ func TestMergeSessions(t *testing.T) {
 		t.Errorf("expected 1672527600000, got %d", sessionsByYear[0].Date.UnixMilli())
 	}
 }
+
+func TestNewCodingSessionsGroupsBuffersByDay(t *testing.T) {
+	t.Parallel()
+
+	// 23:50 Sunday June 16 2024
+	lateNight := time.Date(2024, time.June, 16, 23, 50, 0, 0, time.Local)
+	// 00:05 Monday June 17 2024
+	earlyMorning := lateNight.Add(15 * time.Minute)
+
+	buffers := pulse.Buffers{
+		{OpenedAt: lateNight, Duration: 5 * time.Minute, Repository: "pulse", Filepath: "pulse/logdb.go"},
+		{OpenedAt: earlyMorning, Duration: 10 * time.Minute, Repository: "pulse", Filepath: "pulse/logdb.go"},
+		{OpenedAt: earlyMorning, Duration: 20 * time.Minute, Repository: "dotfiles", Filepath: "dotfiles/install.sh"},
+	}
+
+	sessions := pulse.NewCodingSessions(buffers)
+	if len(sessions) != 2 {
+		t.Fatalf("expected 2 sessions, got %d", len(sessions))
+	}
+
+	if sessions[0].DateString() != "2024-06-16" {
+		t.Errorf("expected the first session to be dated 2024-06-16, got %s", sessions[0].DateString())
+	}
+	if sessions[0].Duration != 5*time.Minute {
+		t.Errorf("expected the first session to be 5 minutes, got %v", sessions[0].Duration)
+	}
+
+	if sessions[1].DateString() != "2024-06-17" {
+		t.Errorf("expected the second session to be dated 2024-06-17, got %s", sessions[1].DateString())
+	}
+	if sessions[1].Duration != 30*time.Minute {
+		t.Errorf("expected the second session to be 30 minutes, got %v", sessions[1].Duration)
+	}
+}
+
+func TestCodingSessionDurationsByBranchEditorAndMachine(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, time.June, 16, 12, 0, 0, 0, time.Local)
+	buffers := pulse.Buffers{
+		{
+			OpenedAt: now, Duration: 10 * time.Minute, Repository: "pulse", Filepath: "pulse/logdb.go",
+			Branch: "main", Editor: "nvim", OS: "darwin", Hostname: "laptop",
+		},
+		{
+			OpenedAt: now, Duration: 20 * time.Minute, Repository: "pulse", Filepath: "pulse/logdb.go",
+			Branch: "outbox", Editor: "nvim", OS: "linux", Hostname: "desktop",
+		},
+		{
+			OpenedAt: now, Duration: 5 * time.Minute, Repository: "pulse", Filepath: "pulse/server.go",
+			Branch: "outbox", Editor: "vscode", OS: "linux", Hostname: "desktop",
+		},
+	}
+
+	session := pulse.NewCodingSession(buffers, now)
+	if len(session.Repositories[0].Files) != 2 {
+		t.Errorf("expected the files opened by several editors to be merged, got %d files", len(session.Repositories[0].Files))
+	}
+	branches := session.Repositories[0].Branches
+	if branches["main"] != 10*time.Minute || branches["outbox"] != 25*time.Minute {
+		t.Errorf("unexpected branch durations: %v", branches)
+	}
+	if session.Editors["nvim"] != 30*time.Minute || session.Editors["vscode"] != 5*time.Minute {
+		t.Errorf("unexpected editor durations: %v", session.Editors)
+	}
+	if session.Machines["laptop"] != 10*time.Minute || session.Machines["desktop"] != 25*time.Minute {
+		t.Errorf("unexpected machine durations: %v", session.Machines)
+	}
+	if session.OperatingSystems["darwin"] != 10*time.Minute || session.OperatingSystems["linux"] != 25*time.Minute {
+		t.Errorf("unexpected operating system durations: %v", session.OperatingSystems)
+	}
+
+	merged := session.Merge(session)
+	if merged.Machines["desktop"] != 50*time.Minute {
+		t.Errorf("expected the merged desktop duration to be 50 minutes, got %v", merged.Machines["desktop"])
+	}
+}
+
+func TestTicketsReport(t *testing.T) {
+	t.Parallel()
+
+	day := time.Date(2024, time.June, 16, 0, 0, 0, 0, time.Local)
+	sessions := pulse.CodingSessions{
+		{Date: day.AddDate(0, 0, -1), Tickets: pulse.Durations{"PULSE-1": time.Hour}},
+		{Date: day, Tickets: pulse.Durations{"PULSE-1": time.Hour, "PULSE-2": 30 * time.Minute}},
+		{Date: day.AddDate(0, 0, 1), Tickets: pulse.Durations{"PULSE-2": time.Hour}},
+		{Date: day.AddDate(0, 0, 2), Tickets: pulse.Durations{"PULSE-3": time.Hour}},
+	}
+
+	tickets := sessions.Tickets(day, day.AddDate(0, 0, 1))
+	if len(tickets) != 2 {
+		t.Fatalf("expected 2 tickets, got %d", len(tickets))
+	}
+	if tickets["PULSE-1"] != time.Hour {
+		t.Errorf("expected PULSE-1 to be 1h, got %v", tickets["PULSE-1"])
+	}
+	if tickets["PULSE-2"] != 90*time.Minute {
+		t.Errorf("expected PULSE-2 to be 1h30m, got %v", tickets["PULSE-2"])
+	}
+}
+
+func TestRepositoriesWithTheSameNameAreKeptApart(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, time.June, 16, 12, 0, 0, 0, time.Local)
+	buffers := pulse.Buffers{
+		{OpenedAt: now, Duration: 10 * time.Minute, Repository: "api", RepositoryID: "github.com/us/api", Filepath: "api/main.go"},
+		{OpenedAt: now, Duration: 20 * time.Minute, Repository: "api", RepositoryID: "gitlab.com/client/api", Filepath: "api/main.go"},
+	}
+
+	session := pulse.NewCodingSession(buffers, now)
+	if len(session.Repositories) != 2 {
+		t.Fatalf("expected 2 repositories, got %d", len(session.Repositories))
+	}
+
+	merged := session.Merge(session)
+	if len(merged.Repositories) != 2 {
+		t.Fatalf("expected 2 repositories after merging, got %d", len(merged.Repositories))
+	}
+	for _, repo := range merged.Repositories {
+		if repo.Name != "api" {
+			t.Errorf("expected the short name to be kept, got %s", repo.Name)
+		}
+		if repo.ID == "gitlab.com/client/api" && repo.Duration != 40*time.Minute {
+			t.Errorf("expected the gitlab repository to be 40 minutes, got %v", repo.Duration)
+		}
+	}
+}
+
+func TestLegacyRepositoriesAreMergedByName(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, time.June, 16, 12, 0, 0, 0, time.Local)
+
+	// The first session was stored before the repositories were qualified.
+	legacy := pulse.NewCodingSession(pulse.Buffers{
+		{OpenedAt: now, Duration: 10 * time.Minute, Repository: "pulse", Filepath: "pulse/main.go"},
+		{OpenedAt: now, Duration: 5 * time.Minute, Repository: "api", Filepath: "api/main.go"},
+	}, now)
+	qualified := pulse.NewCodingSession(pulse.Buffers{
+		{OpenedAt: now, Duration: 20 * time.Minute, Repository: "pulse", RepositoryID: "github.com/viccon/pulse", Filepath: "pulse/main.go"},
+		{OpenedAt: now, Duration: 5 * time.Minute, Repository: "api", RepositoryID: "github.com/us/api", Filepath: "api/main.go"},
+		{OpenedAt: now, Duration: 5 * time.Minute, Repository: "api", RepositoryID: "gitlab.com/client/api", Filepath: "api/main.go"},
+	}, now)
+
+	for _, merged := range []pulse.CodingSession{legacy.Merge(qualified), qualified.Merge(legacy)} {
+		if len(merged.Repositories) != 4 {
+			t.Fatalf("expected 4 repositories, got %d", len(merged.Repositories))
+		}
+		for _, repo := range merged.Repositories {
+			switch {
+			case repo.Name == "pulse" && (repo.ID != "github.com/viccon/pulse" || repo.Duration != 30*time.Minute):
+				t.Errorf("expected the legacy repository to be merged into the qualified one, got %+v", repo)
+			case repo.Name == "api" && repo.ID == "" && repo.Duration != 5*time.Minute:
+				t.Errorf("expected the ambiguous legacy repository to be kept apart, got %+v", repo)
+			}
+		}
+	}
+}